@@ -38,7 +38,11 @@ func DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst any) error {
 
 	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
 
-	dec := json.NewDecoder(r.Body)
+	return decodeJSON(r.Body, dst)
+}
+
+func decodeJSON(body io.Reader, dst any) error {
+	dec := json.NewDecoder(body)
 	dec.DisallowUnknownFields()
 
 	err := dec.Decode(&dst)
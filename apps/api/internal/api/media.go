@@ -2,12 +2,9 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +12,7 @@ import (
 	"github.com/patrickmn/go-cache"
 
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/telegram"
 )
 
 const (
@@ -52,20 +50,9 @@ type DetectedStreamContext struct {
 	ContentType string
 }
 
-type GetFileResponse struct {
-	Ok     bool `json:"ok"`
-	Result struct {
-		FileID       string `json:"file_id"`
-		FileUniqueID string `json:"file_unique_id"`
-		FilePath     string `json:"file_path"`
-		FileSize     int    `json:"file_size"`
-	} `json:"result"`
-}
-
 func (h *Handler) mediaHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	retries := h.cfg.DownloadRetries()
-	token := h.cfg.TelegramToken()
 
 	fileID, err := extractFileID(r)
 	if err != nil {
@@ -73,7 +60,7 @@ func (h *Handler) mediaHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fileInfo, err := getCachedOrFetchFileInfo(ctx, token, fileID, retries)
+	fileInfo, err := getCachedOrFetchFileInfo(ctx, fileID, retries)
 	if err != nil {
 		log.Printf("Error fetching file info for %s: %v\n", fileID, err)
 		http.Error(w, "failed getting a download link", http.StatusBadGateway)
@@ -96,7 +83,6 @@ func (h *Handler) mediaHandler(w http.ResponseWriter, r *http.Request) {
 
 func getCachedOrFetchFileInfo(
 	ctx context.Context,
-	token string,
 	fileID string,
 	retries int,
 ) (*CachedFileInfo, error) {
@@ -108,7 +94,7 @@ func getCachedOrFetchFileInfo(
 		return nil, err
 	}
 
-	fileURL, err := downloadLink(ctx, token, fileID, retries)
+	fileURL, err := telegram.FileURL(ctx, fileID, retries)
 	if err != nil {
 		return nil, err
 	}
@@ -263,75 +249,7 @@ func downloadFile(
 		Request: req,
 		Client:  httpClient,
 		Retries: retries,
+		Name:    telegram.RedactToken(fileURL),
 	}
 	return retrier.Download(params)
 }
-
-func downloadLink(
-	ctx context.Context,
-	token string,
-	fileID string,
-	retries int,
-) (string, error) {
-	if err := ctx.Err(); err != nil {
-		return "", err
-	}
-
-	reqURL := fmt.Sprintf(
-		"https://api.telegram.org/bot%s/getFile?file_id=%s",
-		token,
-		url.QueryEscape(fileID),
-	)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed creating request: %w", err)
-	}
-	params := &retrier.RetryParams{
-		Request: req,
-		Client:  httpClient,
-		Retries: retries,
-	}
-	resp, err := retrier.RequestWithCallback(ctx, params, downloadLinkCallback)
-	if err != nil {
-		return "", fmt.Errorf("failed getting a download link: %w", err)
-	}
-	defer resp.Body.Close()
-
-	fileResp, err := parseGetFileResponse(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	if !fileResp.Ok {
-		return "", fmt.Errorf("telegram API returned not ok")
-	}
-	if fileResp.Result.FilePath == "" {
-		return "", fmt.Errorf("file_path is empty")
-	}
-
-	fileURL := fmt.Sprintf(
-		"https://api.telegram.org/file/bot%s/%s",
-		token,
-		fileResp.Result.FilePath,
-	)
-	return fileURL, nil
-}
-
-func downloadLinkCallback(resp *http.Response) (bool, error) {
-	switch resp.StatusCode {
-	case http.StatusOK:
-		return false, nil
-	default:
-		return true, fmt.Errorf(
-			"tg request for download link status code %d", resp.StatusCode,
-		)
-	}
-}
-
-func parseGetFileResponse(r io.Reader) (*GetFileResponse, error) {
-	var fileResp GetFileResponse
-	if err := json.NewDecoder(r).Decode(&fileResp); err != nil {
-		return nil, fmt.Errorf("failed to decode getFile response: %w", err)
-	}
-	return &fileResp, nil
-}
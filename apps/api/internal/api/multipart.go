@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+const (
+	maxMultipartSize = 64 * 1024 * 1024 // 64 MB for all files together
+	payloadField     = "payload"
+)
+
+// decodePackBody accepts either a plain JSON body or a multipart form
+// with the JSON in the payload field and the uploaded files next to it
+func decodePackBody(
+	w http.ResponseWriter,
+	r *http.Request,
+	dst any,
+) (map[string][]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return nil, DecodeJSONBody(w, r, dst)
+	}
+	return DecodeMultipartBody(w, r, dst)
+}
+
+func DecodeMultipartBody(
+	w http.ResponseWriter,
+	r *http.Request,
+	dst any,
+) (map[string][]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxMultipartSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		msg := "Request body is not a valid multipart form"
+		return nil, &malformedRequest{status: http.StatusBadRequest, msg: msg}
+	}
+
+	uploads := make(map[string][]byte)
+	payloadFound := false
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, multipartReadError(err)
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			if name != payloadField {
+				msg := fmt.Sprintf("Request contains unknown field %q", name)
+				return nil, &malformedRequest{status: http.StatusBadRequest, msg: msg}
+			}
+			if payloadFound {
+				msg := "Request must only contain a single payload"
+				return nil, &malformedRequest{status: http.StatusBadRequest, msg: msg}
+			}
+			payload := http.MaxBytesReader(w, part, maxSize)
+			if err := decodeJSON(payload, dst); err != nil {
+				return nil, err
+			}
+			payloadFound = true
+			continue
+		}
+
+		if _, exists := uploads[name]; exists {
+			msg := fmt.Sprintf("File %q is uploaded more than once", name)
+			return nil, &malformedRequest{status: http.StatusBadRequest, msg: msg}
+		}
+		data, err := readUpload(part)
+		if err != nil {
+			return nil, err
+		}
+		uploads[name] = data
+	}
+
+	if !payloadFound {
+		msg := fmt.Sprintf("Request is missing the %s field", payloadField)
+		return nil, &malformedRequest{status: http.StatusBadRequest, msg: msg}
+	}
+
+	return uploads, nil
+}
+
+func readUpload(part *multipart.Part) ([]byte, error) {
+	name := part.FormName()
+	data, err := io.ReadAll(io.LimitReader(part, emote.MaxUploadSize+1))
+	if err != nil {
+		return nil, multipartReadError(err)
+	}
+
+	if err := emote.ValidateUpload(data); err != nil {
+		// only the size is 413, heic and unknown files are the wrong type
+		status := http.StatusUnsupportedMediaType
+		if errors.Is(err, emote.ErrorUploadTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		msg := fmt.Sprintf("File %q: %v", name, err)
+		return nil, &malformedRequest{status: status, msg: msg}
+	}
+
+	return data, nil
+}
+
+func multipartReadError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		msg := fmt.Sprintf(
+			"Request body must not be larger than %d bytes",
+			maxBytesError.Limit,
+		)
+		return &malformedRequest{
+			status: http.StatusRequestEntityTooLarge,
+			msg:    msg,
+		}
+	}
+	msg := "Request body contains a malformed multipart form"
+	return &malformedRequest{status: http.StatusBadRequest, msg: msg}
+}
+
+// attachUploads hands the uploaded files to the emotes that reference them
+func attachUploads(
+	emotes []emote.EmoteInput,
+	uploads map[string][]byte,
+) *malformedRequest {
+	for i := range emotes {
+		if emotes[i].Source != emote.UploadSource {
+			continue
+		}
+		data, ok := uploads[emotes[i].ID]
+		if !ok {
+			return &malformedRequest{
+				status: http.StatusBadRequest,
+				msg:    fmt.Sprintf("no file uploaded for %q", emotes[i].ID),
+			}
+		}
+		emotes[i].Upload = data
+	}
+	return nil
+}
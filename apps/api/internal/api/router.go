@@ -2,11 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/db"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emotecache"
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/queue"
 )
 
@@ -19,6 +22,7 @@ const (
 	mediaRoute       = "/media"
 	jobStatusRoute   = "/job/"
 	queueStatusRoute = "/queue"
+	sourcesRoute     = "/sources"
 )
 
 var noAuthRoutes = []NoAuthRoute{
@@ -28,6 +32,7 @@ var noAuthRoutes = []NoAuthRoute{
 	{Path: baseRoute + mediaRoute, Method: http.MethodGet, PrefixMatch: false},
 	// {Path: baseRoute + jobStatusRoute, Method: http.MethodGet, PrefixMatch: true},
 	{Path: baseRoute + queueStatusRoute, Method: http.MethodGet, PrefixMatch: false},
+	{Path: baseRoute + sourcesRoute, Method: http.MethodGet, PrefixMatch: false},
 	{Path: "", Method: http.MethodOptions, PrefixMatch: true}, // preflight
 }
 
@@ -35,6 +40,7 @@ type Handler struct {
 	cfg   *config.Config
 	db    *db.Postgres
 	queue *queue.Queue
+	cache *emotecache.Cache
 }
 
 func withCORS(domain string, next http.Handler) http.Handler {
@@ -75,6 +81,7 @@ func SetupHandler(cfg *config.Config, dbConn *db.Postgres) http.Handler {
 		cfg:   cfg,
 		db:    dbConn,
 		queue: queue.NewQueue(cfg.QueueWorkers()),
+		cache: newEmoteCache(cfg),
 	}
 
 	mux := http.NewServeMux()
@@ -87,6 +94,7 @@ func SetupHandler(cfg *config.Config, dbConn *db.Postgres) http.Handler {
 	api.HandleFunc(mediaRoute, h.mediaHandler)
 	api.HandleFunc(jobStatusRoute, h.jobStatusHandler)
 	api.HandleFunc(queueStatusRoute, h.queueStatsHandler)
+	api.HandleFunc(sourcesRoute, h.sourcesHandler)
 
 	mux.Handle(baseRoute+"/", http.StripPrefix(baseRoute, api))
 
@@ -97,6 +105,20 @@ func SetupHandler(cfg *config.Config, dbConn *db.Postgres) http.Handler {
 	return cors
 }
 
+// newEmoteCache returns nil when the cache is disabled or broken,
+// packs are still made, just slower
+func newEmoteCache(cfg *config.Config) *emotecache.Cache {
+	if cfg.EmoteCacheDir() == "" {
+		return nil
+	}
+	cache, err := emotecache.New(cfg.EmoteCacheDir(), cfg.EmoteCacheSize())
+	if err != nil {
+		log.Printf("warn: emote cache disabled: %v", err)
+		return nil
+	}
+	return cache
+}
+
 func (h *Handler) publicPacksHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -176,3 +198,13 @@ func (h *Handler) queueStatsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
+
+func (h *Handler) sourcesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(emote.EnabledSources())
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/db"
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emotecache"
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/resize"
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/telegram"
 	"golang.org/x/sync/errgroup"
@@ -38,6 +39,11 @@ type CreatePackRequest struct {
 	Emotes       []emote.EmoteInput `json:"emotes"`
 	IsPublic     bool               `json:"is_public"`
 	HasWatermark bool               `json:"has_watermark"`
+	// regular or custom_emoji, empty means regular
+	StickerType     string `json:"sticker_type,omitempty"`
+	NeedsRepainting bool   `json:"needs_repainting,omitempty"`
+	// used by emotes that don't set their own
+	Outline *emote.Outline `json:"outline,omitempty"`
 }
 
 type CreatePackResponse struct {
@@ -70,6 +76,15 @@ var format = map[bool]string{
 	false: "static",
 }
 
+// telegram calls lottie stickers "animated"
+const tgsFormat = "animated"
+
+// output size for each sticker set type
+var profiles = map[string]resize.Profile{
+	telegram.StickerTypeRegular:     resize.StickerProfile,
+	telegram.StickerTypeCustomEmoji: resize.EmojiProfile,
+}
+
 func (h *Handler) deletePackHandler(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -186,20 +201,23 @@ func applyWatermark(
 }
 
 type CreatePackJobHandler struct {
-	cfg *config.Config
-	db  *db.Postgres
-	req *CreatePackRequest
+	cfg   *config.Config
+	db    *db.Postgres
+	cache *emotecache.Cache
+	req   *CreatePackRequest
 }
 
 func NewCreatePackJobHandler(
 	cfg *config.Config,
 	dbConn *db.Postgres,
+	cache *emotecache.Cache,
 	req *CreatePackRequest,
 ) *CreatePackJobHandler {
 	return &CreatePackJobHandler{
-		cfg: cfg,
-		db:  dbConn,
-		req: req,
+		cfg:   cfg,
+		db:    dbConn,
+		cache: cache,
+		req:   req,
 	}
 }
 
@@ -214,7 +232,7 @@ func (h *Handler) createPackHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handler := NewCreatePackJobHandler(h.cfg, h.db, req)
+	handler := NewCreatePackJobHandler(h.cfg, h.db, h.cache, req)
 
 	jobID, err := h.queue.Enqueue(handler, r)
 	if err != nil {
@@ -234,7 +252,9 @@ func (h *Handler) createPackHandler(w http.ResponseWriter, r *http.Request) {
 
 func emotesToStickers(
 	ctx context.Context,
+	cache *emotecache.Cache,
 	emotes []emote.EmoteInput,
+	profile resize.Profile,
 	limit int,
 	progress func(done, total int),
 ) ([]telegram.InputSticker, error) {
@@ -256,7 +276,7 @@ func emotesToStickers(
 				return ctx.Err()
 			}
 
-			sticker, err := parseEmote(ctx, input)
+			sticker, err := parseEmote(ctx, cache, input, profile)
 			if err != nil {
 				return err
 			}
@@ -278,7 +298,9 @@ func emotesToStickers(
 
 func parseEmote(
 	ctx context.Context,
+	cache *emotecache.Cache,
 	input emote.EmoteInput,
+	profile resize.Profile,
 ) (telegram.InputSticker, error) {
 	emote, err := input.ToEmote()
 	if err != nil {
@@ -289,7 +311,7 @@ func parseEmote(
 		return telegram.InputSticker{}, err
 	}
 
-	emoteData, err := emote.Download(ctx)
+	emoteData, err := cache.Download(ctx, &input, emote)
 	if err != nil {
 		return telegram.InputSticker{}, err
 	}
@@ -298,15 +320,29 @@ func parseEmote(
 		return telegram.InputSticker{}, err
 	}
 
-	if err := resize.FitEmote(&emoteData); err != nil {
-		return telegram.InputSticker{}, err
+	// lottie can't be resized, it only fits regular sticker sets
+	if emoteData.TGS && profile != resize.StickerProfile {
+		return telegram.InputSticker{}, fmt.Errorf(
+			"%s is an animated sticker and can't be used as an emoji", emote,
+		)
+	}
+
+	// the resizer can find animation the source didn't report
+	stickerFormat := tgsFormat
+	if !emoteData.TGS {
+		err := cache.FitEmote(ctx, &emoteData, input.Options, profile)
+		if err != nil {
+			return telegram.InputSticker{}, err
+		}
+		stickerFormat = format[emoteData.Animated]
 	}
 
+	keywords, emojiList := input.Autofill(emote, &emoteData)
 	return telegram.InputSticker{
 		Sticker:   emoteData.File,
-		Format:    format[emoteData.Animated],
-		Keywords:  emote.Keywords(),
-		EmojiList: emote.EmojiList(),
+		Format:    stickerFormat,
+		Keywords:  keywords,
+		EmojiList: emojiList,
 	}, nil
 }
 
@@ -316,13 +352,16 @@ func (h *CreatePackJobHandler) Handle(
 	progress func(done, total int, message string),
 ) (any, error) {
 	req := h.req
-	steps := 3 + len(req.Emotes)
+	emotes := req.Emotes
+	steps := 3 + len(emotes)
 	currentStep := 0
 
 	progress(currentStep, steps, "Processing emotes")
 	stickers, err := emotesToStickers(
 		ctx,
-		req.Emotes,
+		h.cache,
+		emotes,
+		profiles[req.StickerType],
 		2,
 		func(done, total int) {
 			currentStep = steps - total + done
@@ -346,6 +385,8 @@ func (h *CreatePackJobHandler) Handle(
 		telegram.WithStickers(stickers),
 		telegram.WithTitle(watermarkTitle),
 		telegram.WithPublic(req.IsPublic),
+		telegram.WithStickerType(req.StickerType),
+		telegram.WithNeedsRepainting(req.NeedsRepainting),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to bundle stickerpack: %w", err)
@@ -393,7 +434,7 @@ func parseCreatePackRequest(
 	req *CreatePackRequest,
 	mr *malformedRequest,
 ) {
-	err := DecodeJSONBody(w, r, &req)
+	uploads, err := decodePackBody(w, r, &req)
 	if err != nil {
 		if errors.As(err, &mr) {
 			return
@@ -422,6 +463,25 @@ func parseCreatePackRequest(
 		return
 	}
 
+	if req.StickerType == "" {
+		req.StickerType = telegram.StickerTypeRegular
+	}
+	if _, ok := profiles[req.StickerType]; !ok {
+		mr = &malformedRequest{
+			status: http.StatusBadRequest,
+			msg:    fmt.Sprintf("unknown sticker type %q", req.StickerType),
+		}
+		return
+	}
+	if req.NeedsRepainting &&
+		req.StickerType != telegram.StickerTypeCustomEmoji {
+		mr = &malformedRequest{
+			status: http.StatusBadRequest,
+			msg:    "only custom emoji can be repainted",
+		}
+		return
+	}
+
 	emoteCount := len(req.Emotes)
 	if emoteCount == 0 {
 		mr = &malformedRequest{
@@ -431,6 +491,28 @@ func parseCreatePackRequest(
 		return
 	}
 
+	if req.Outline != nil {
+		if err := req.Outline.Validate(); err != nil {
+			mr = &malformedRequest{
+				status: http.StatusBadRequest,
+				msg:    err.Error(),
+			}
+			return
+		}
+		for i := range req.Emotes {
+			if req.Emotes[i].Outline == nil {
+				req.Emotes[i].Outline = req.Outline
+			}
+		}
+	}
+
+	if mr = attachUploads(req.Emotes, uploads); mr != nil {
+		return
+	}
+	if mr = validateInputs(req.Emotes); mr != nil {
+		return
+	}
+
 	userID, ctxErr := UserIDFromContext(r)
 	if ctxErr != nil {
 		mr = &malformedRequest{
@@ -441,6 +523,19 @@ func parseCreatePackRequest(
 	}
 	req.UserID = userID
 
+	// sets are expanded here so a bad set fails the request, not the job
+	emotes, err := emote.ExpandInputs(
+		r.Context(), req.Emotes, telegram.MaxStickers(req.StickerType),
+	)
+	if err != nil {
+		mr = &malformedRequest{
+			status: http.StatusBadRequest,
+			msg:    fmt.Sprintf("failed to expand emotes: %v", err),
+		}
+		return
+	}
+	req.Emotes = emotes
+
 	return
 }
 
@@ -554,20 +649,23 @@ func (h *Handler) nameExistsHandler(
 }
 
 type EditPackJobHandler struct {
-	cfg *config.Config
-	db  *db.Postgres
-	req *EditPackRequest
+	cfg   *config.Config
+	db    *db.Postgres
+	cache *emotecache.Cache
+	req   *EditPackRequest
 }
 
 func NewEditPackJobHandler(
 	cfg *config.Config,
 	dbConn *db.Postgres,
+	cache *emotecache.Cache,
 	req *EditPackRequest,
 ) *EditPackJobHandler {
 	return &EditPackJobHandler{
-		cfg: cfg,
-		db:  dbConn,
-		req: req,
+		cfg:   cfg,
+		db:    dbConn,
+		cache: cache,
+		req:   req,
 	}
 }
 
@@ -586,7 +684,7 @@ func (h *Handler) editPackHandler(
 		return
 	}
 
-	handler := NewEditPackJobHandler(h.cfg, h.db, req)
+	handler := NewEditPackJobHandler(h.cfg, h.db, h.cache, req)
 
 	jobID, err := h.queue.Enqueue(handler, r)
 	if err != nil {
@@ -612,7 +710,7 @@ func parseEditPackRequest(
 	req *EditPackRequest,
 	mr *malformedRequest,
 ) {
-	err := DecodeJSONBody(w, r, &req)
+	uploads, err := decodePackBody(w, r, &req)
 	if err != nil {
 		if errors.As(err, &mr) {
 			return
@@ -625,6 +723,13 @@ func parseEditPackRequest(
 		return
 	}
 
+	if mr = attachUploads(req.AddedStickers, uploads); mr != nil {
+		return
+	}
+	if mr = validateInputs(req.AddedStickers); mr != nil {
+		return
+	}
+
 	userID, ctxErr := UserIDFromContext(r)
 	if ctxErr != nil {
 		mr = &malformedRequest{
@@ -638,6 +743,19 @@ func parseEditPackRequest(
 	return
 }
 
+// validateInputs rejects emotes that would only fail once the job runs
+func validateInputs(inputs []emote.EmoteInput) *malformedRequest {
+	for i := range inputs {
+		if err := inputs[i].Validate(); err != nil {
+			return &malformedRequest{
+				status: http.StatusBadRequest,
+				msg:    fmt.Sprintf("emote %d: %v", i+1, err),
+			}
+		}
+	}
+	return nil
+}
+
 type editProgress struct {
 	current int
 	total   int
@@ -664,6 +782,23 @@ func (h *EditPackJobHandler) Handle(
 ) (any, error) {
 	req := h.req
 	name := req.PackName
+	var set *telegram.StickerSet
+	if len(req.AddedStickers) > 0 {
+		// added stickers only get the room left in the set
+		var err error
+		set, err = telegram.FetchPack(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch pack: %w", err)
+		}
+		room := telegram.MaxStickers(set.StickerType) -
+			len(set.Stickers) + len(req.DeletedStickers)
+		added, err := emote.ExpandInputs(ctx, req.AddedStickers, room)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand emotes: %w", err)
+		}
+		req.AddedStickers = added
+	}
+
 	prog := &editProgress{
 		current: 0,
 		total:   calculateEditSteps(req),
@@ -690,7 +825,8 @@ func (h *EditPackJobHandler) Handle(
 	if err := editUpdateTitleStage(req, pack, prog); err != nil {
 		return nil, fmt.Errorf("failed to update title: %w", err)
 	}
-	if err := editAddStage(ctx, pack, req.AddedStickers, prog); err != nil {
+	err = editAddStage(ctx, h.cache, pack, set, req.AddedStickers, prog)
+	if err != nil {
 		return nil, fmt.Errorf("failed to add stickers: %w", err)
 	}
 	if err := editPositionStage(req.PositionUpdates, prog); err != nil {
@@ -733,7 +869,9 @@ func editDeleteStage(deletedIDs []string, prog *editProgress) error {
 
 func editProcessStage(
 	ctx context.Context,
+	cache *emotecache.Cache,
 	addedStickers []emote.EmoteInput,
+	profile resize.Profile,
 	prog *editProgress,
 ) ([]telegram.InputSticker, error) {
 	if len(addedStickers) == 0 {
@@ -744,7 +882,9 @@ func editProcessStage(
 
 	stickers, err := emotesToStickers(
 		ctx,
+		cache,
 		addedStickers,
+		profile,
 		2,
 		func(done, total int) {
 			prog.current = prog.total - (total * 2) + done
@@ -794,11 +934,23 @@ func editUpdateTitleStage(
 
 func editAddStage(
 	ctx context.Context,
+	cache *emotecache.Cache,
 	pack *telegram.StickerPack,
+	set *telegram.StickerSet,
 	addedStickers []emote.EmoteInput,
 	prog *editProgress,
 ) error {
-	stickers, err := editProcessStage(ctx, addedStickers, prog)
+	if len(addedStickers) == 0 {
+		return nil
+	}
+
+	// added stickers have to match the size of the existing set
+	profile, ok := profiles[set.StickerType]
+	if !ok {
+		return fmt.Errorf("unsupported sticker type %q", set.StickerType)
+	}
+
+	stickers, err := editProcessStage(ctx, cache, addedStickers, profile, prog)
 	if err != nil {
 		return fmt.Errorf("failed to process emotes: %w", err)
 	}
@@ -2,8 +2,11 @@ package config
 
 import (
 	"log"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/env"
 	"github.com/joho/godotenv"
@@ -17,6 +20,16 @@ type Config struct {
 	secretKey       string
 	downloadRetries int
 	queueWorkers    int
+	bttvAPIURL      string
+	bttvCDNURL      string
+	emoteSources    []string
+	tenorAPIKey     string
+	emoteCacheDir   string
+	emoteCacheSize  int64
+	ffmpegProcesses int
+	ffmpegTimeout   time.Duration
+	ffmpegMemory    int64
+	emojiFontPath   string
 }
 
 var (
@@ -24,13 +37,23 @@ var (
 	once sync.Once
 )
 
-func (c *Config) TelegramToken() string { return c.telegramToken }
-func (c *Config) Port() string          { return c.port }
-func (c *Config) BotName() string       { return c.botName }
-func (c *Config) Domain() string        { return c.domain }
-func (c *Config) SecretKey() string     { return c.secretKey }
-func (c *Config) DownloadRetries() int  { return c.downloadRetries }
-func (c *Config) QueueWorkers() int     { return c.queueWorkers }
+func (c *Config) TelegramToken() string        { return c.telegramToken }
+func (c *Config) Port() string                 { return c.port }
+func (c *Config) BotName() string              { return c.botName }
+func (c *Config) Domain() string               { return c.domain }
+func (c *Config) SecretKey() string            { return c.secretKey }
+func (c *Config) DownloadRetries() int         { return c.downloadRetries }
+func (c *Config) QueueWorkers() int            { return c.queueWorkers }
+func (c *Config) BTTVAPIURL() string           { return c.bttvAPIURL }
+func (c *Config) BTTVCDNURL() string           { return c.bttvCDNURL }
+func (c *Config) EmoteSources() []string       { return c.emoteSources }
+func (c *Config) TenorAPIKey() string          { return c.tenorAPIKey }
+func (c *Config) EmoteCacheDir() string        { return c.emoteCacheDir }
+func (c *Config) EmoteCacheSize() int64        { return c.emoteCacheSize }
+func (c *Config) FFmpegProcesses() int         { return c.ffmpegProcesses }
+func (c *Config) FFmpegTimeout() time.Duration { return c.ffmpegTimeout }
+func (c *Config) FFmpegMemoryLimit() int64     { return c.ffmpegMemory }
+func (c *Config) EmojiFontPath() string        { return c.emojiFontPath }
 
 func Load() *Config {
 	once.Do(func() {
@@ -52,6 +75,46 @@ func Load() *Config {
 			log.Fatalln("QUEUE_WORKERS is not a number")
 		}
 
+		emoteCacheMB, err := strconv.ParseInt(
+			env.Fallback("EMOTE_CACHE_SIZE_MB", "1024"), 10, 64,
+		)
+		if err != nil {
+			log.Fatalln("EMOTE_CACHE_SIZE_MB is not a number")
+		}
+
+		// 0 means one process per cpu
+		ffmpegProcesses, err := strconv.Atoi(env.Fallback("FFMPEG_PROCESSES", "0"))
+		if err != nil {
+			log.Fatalln("FFMPEG_PROCESSES is not a number")
+		}
+		if ffmpegProcesses <= 0 {
+			ffmpegProcesses = runtime.NumCPU()
+		}
+
+		ffmpegTimeout, err := strconv.Atoi(
+			env.Fallback("FFMPEG_TIMEOUT_SECONDS", "120"),
+		)
+		if err != nil {
+			log.Fatalln("FFMPEG_TIMEOUT_SECONDS is not a number")
+		}
+
+		// caps the virtual memory of each ffmpeg process, which is far more
+		// than it actually uses, so it's off by default. 0 disables the limit
+		ffmpegMemoryMB, err := strconv.ParseInt(
+			env.Fallback("FFMPEG_MEMORY_LIMIT_MB", "0"), 10, 64,
+		)
+		if err != nil {
+			log.Fatalln("FFMPEG_MEMORY_LIMIT_MB is not a number")
+		}
+
+		// empty means every source is enabled
+		var emoteSources []string
+		for _, name := range strings.Split(env.Fallback("EMOTE_SOURCES", ""), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				emoteSources = append(emoteSources, name)
+			}
+		}
+
 		cfg = &Config{
 			port:            env.Fallback("PORT", "8080"),
 			domain:          env.Must("DOMAIN"),
@@ -60,6 +123,23 @@ func Load() *Config {
 			secretKey:       secretKey,
 			downloadRetries: downloadRetries,
 			queueWorkers:    queueWorkers,
+			bttvAPIURL: env.Fallback(
+				"BTTV_API_URL", "https://api.betterttv.net/3",
+			),
+			bttvCDNURL: env.Fallback(
+				"BTTV_CDN_URL", "https://cdn.betterttv.net",
+			),
+			emoteSources: emoteSources,
+			tenorAPIKey:  env.Fallback("TENOR_API_KEY", ""),
+			// empty disables the cache
+			emoteCacheDir:  env.Fallback("EMOTE_CACHE_DIR", ""),
+			emoteCacheSize: emoteCacheMB * 1024 * 1024,
+
+			ffmpegProcesses: ffmpegProcesses,
+			ffmpegTimeout:   time.Duration(ffmpegTimeout) * time.Second,
+			ffmpegMemory:    ffmpegMemoryMB * 1024 * 1024,
+			// outline font for emoji in text, color bitmap fonts can't be drawn
+			emojiFontPath: env.Fallback("EMOJI_FONT_PATH", "/app/fonts/NotoEmoji.ttf"),
 		}
 	})
 
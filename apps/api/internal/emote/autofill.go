@@ -0,0 +1,206 @@
+package emote
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	maxSuggestedEmojis = 3
+	// telegram needs at least one
+	defaultEmoji = "😀"
+)
+
+// emote and tag words people actually use, checked in lowercase
+var keywordEmojis = map[string]string{
+	"laugh":      "😂",
+	"lol":        "😂",
+	"lul":        "😂",
+	"omegalul":   "😂",
+	"kekw":       "😂",
+	"kek":        "😂",
+	"funny":      "😂",
+	"smile":      "😊",
+	"happy":      "😊",
+	"blush":      "😊",
+	"cry":        "😢",
+	"crying":     "😢",
+	"sad":        "😢",
+	"tears":      "😢",
+	"pepehands":  "😢",
+	"sadge":      "😢",
+	"biblethump": "😢",
+	"love":       "❤️",
+	"heart":      "❤️",
+	"hug":        "🤗",
+	"kiss":       "😘",
+	"hype":       "🔥",
+	"fire":       "🔥",
+	"lit":        "🔥",
+	"pog":        "😮",
+	"poggers":    "😮",
+	"pogchamp":   "😮",
+	"wow":        "😮",
+	"omg":        "😱",
+	"shock":      "😱",
+	"scared":     "😱",
+	"monka":      "😰",
+	"nervous":    "😰",
+	"sweat":      "😅",
+	"think":      "🤔",
+	"thinking":   "🤔",
+	"hmm":        "🤔",
+	"angry":      "😠",
+	"mad":        "😠",
+	"rage":       "😡",
+	"madge":      "😡",
+	"cool":       "😎",
+	"ez":         "😎",
+	"sunglasses": "😎",
+	"smug":       "😏",
+	"kappa":      "😏",
+	"wink":       "😉",
+	"nerd":       "🤓",
+	"clown":      "🤡",
+	"sus":        "🤨",
+	"eyes":       "👀",
+	"look":       "👀",
+	"stare":      "👀",
+	"sleep":      "😴",
+	"tired":      "😴",
+	"sick":       "🤢",
+	"skull":      "💀",
+	"dead":       "💀",
+	"rip":        "💀",
+	"clap":       "👏",
+	"ok":         "👌",
+	"yes":        "👍",
+	"like":       "👍",
+	"no":         "👎",
+	"wave":       "👋",
+	"hi":         "👋",
+	"hello":      "👋",
+	"bye":        "👋",
+	"pray":       "🙏",
+	"please":     "🙏",
+	"facepalm":   "🤦",
+	"shrug":      "🤷",
+	"dance":      "💃",
+	"dancing":    "💃",
+	"jam":        "🎵",
+	"vibe":       "🎵",
+	"music":      "🎵",
+	"party":      "🎉",
+	"celebrate":  "🎉",
+	"gg":         "🏆",
+	"win":        "🏆",
+	"money":      "💰",
+	"coffee":     "☕",
+	"sip":        "☕",
+	"popcorn":    "🍿",
+	"cat":        "🐱",
+	"kitty":      "🐱",
+	"dog":        "🐶",
+	"doge":       "🐶",
+	"pepe":       "🐸",
+	"frog":       "🐸",
+	"peepo":      "🐸",
+	"monkey":     "🐒",
+}
+
+// Autofill returns the keywords and emojis of the sticker, whatever the
+// user left empty is filled from the emote metadata.
+// Tags are always added, the source picked them as keywords already.
+func (e *EmoteInput) Autofill(em Emote, data *EmoteData) ([]string, []string) {
+	keywords := appendKeywords(em.Keywords(), data.Tags)
+	if len(e.Keywords) == 0 {
+		candidates := []string{data.Name}
+		candidates = append(candidates, splitName(data.Name)...)
+		keywords = appendKeywords(keywords, candidates)
+	}
+
+	emojis := em.EmojiList()
+	if len(e.EmojiList) == 0 {
+		emojis = suggestEmojis(data)
+	}
+
+	return keywords, emojis
+}
+
+// suggestEmojis prefers the emojis the source already had,
+// then looks up the name and the tags
+func suggestEmojis(data *EmoteData) []string {
+	var emojis []string
+	seen := make(map[string]bool)
+	add := func(emoji string) {
+		if emoji == "" || seen[emoji] || len(emojis) >= maxSuggestedEmojis {
+			return
+		}
+		seen[emoji] = true
+		emojis = append(emojis, emoji)
+	}
+
+	for _, emoji := range data.Emojis {
+		add(emoji)
+	}
+
+	words := []string{data.Name}
+	words = append(words, splitName(data.Name)...)
+	for _, tag := range data.Tags {
+		words = append(words, tag)
+		words = append(words, strings.Fields(tag)...)
+	}
+	for _, word := range words {
+		add(keywordEmojis[strings.ToLower(word)])
+	}
+
+	if len(emojis) == 0 {
+		return []string{defaultEmoji}
+	}
+	return emojis
+}
+
+// splitName breaks emote names like catJAM or PepeLaugh into words,
+// single letters are dropped since they are usually just suffixes
+func splitName(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && end-start > 1 {
+			words = append(words, string(runes[start:end]))
+		}
+		start = -1
+	}
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+
+		prev := runes[i-1]
+		switch {
+		case unicode.IsLower(prev) && unicode.IsUpper(r),
+			unicode.IsDigit(prev) != unicode.IsDigit(r):
+			flush(i)
+			start = i
+		case unicode.IsUpper(prev) && unicode.IsUpper(r) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			// the last capital of KEKWLaugh starts the next word
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(runes))
+
+	// a name that is a single word is already a keyword
+	if len(words) == 1 && words[0] == name {
+		return nil
+	}
+	return words
+}
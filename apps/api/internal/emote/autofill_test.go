@@ -0,0 +1,79 @@
+package emote
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"catJAM", []string{"cat", "JAM"}},
+		{"PepeLaugh", []string{"Pepe", "Laugh"}},
+		{"KEKWLaugh", []string{"KEKW", "Laugh"}},
+		{"pepe_laugh", []string{"pepe", "laugh"}},
+		{"funny cat", []string{"funny", "cat"}},
+		{"peepo2x", []string{"peepo"}},
+		{"KEKW", nil},
+		{"xD", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitName(tt.name); !slices.Equal(got, tt.want) {
+			t.Errorf("splitName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestEmojis(t *testing.T) {
+	tests := []struct {
+		name string
+		data EmoteData
+		want []string
+	}{
+		{"name words", EmoteData{Name: "PepeLaugh"}, []string{"🐸", "😂"}},
+		{"source emojis first", EmoteData{Name: "catJAM", Emojis: []string{"🐱"}}, []string{"🐱", "🎵"}},
+		{"tag words", EmoteData{Tags: []string{"funny cat"}}, []string{"😂", "🐱"}},
+		{"duplicates", EmoteData{Name: "lol", Tags: []string{"KEKW"}}, []string{"😂"}},
+		{"at most three", EmoteData{Tags: []string{"lol", "sad", "fire", "cool"}}, []string{"😂", "😢", "🔥"}},
+		{"nothing known", EmoteData{Name: "xyz"}, []string{defaultEmoji}},
+	}
+
+	for _, tt := range tests {
+		if got := suggestEmojis(&tt.data); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: suggestEmojis = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// words are looked up in lowercase, other keys would never match
+func TestKeywordEmojis(t *testing.T) {
+	for word, emoji := range keywordEmojis {
+		if word != strings.ToLower(word) {
+			t.Errorf("keyword %q is not lowercase", word)
+		}
+		if emoji == "" {
+			t.Errorf("keyword %q has no emoji", word)
+		}
+	}
+
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"KEKW", "😂"},
+		{"Sadge", "😢"},
+		{"catJAM", ""},
+		{"pepe", "🐸"},
+	}
+
+	for _, tt := range tests {
+		if got := keywordEmojis[strings.ToLower(tt.word)]; got != tt.want {
+			t.Errorf("keywordEmojis[%q] = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package emote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
+)
+
+// BTTV ids are mongo ObjectIDs
+var bttvIDRegex = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+func apiURLBTTV() string {
+	return strings.TrimSuffix(config.Load().BTTVAPIURL(), "/")
+}
+
+func cdnURLBTTV() string {
+	return strings.TrimSuffix(config.Load().BTTVCDNURL(), "/")
+}
+
+var sourceBTTV = register(&Source{
+	Name:     "bttv",
+	New:      newBTTVEmote,
+	Validate: isValidBTTVId,
+	Client: &http.Client{
+		Timeout: 10 * time.Second,
+	},
+	Retries: 3,
+	Constraints: Constraints{
+		IDFormat: "24 character hex emote id",
+		Animated: true,
+	},
+})
+
+// the CDN converts to any of these, the original might be a webp
+var extensionsBTTV = map[bool]string{
+	true:  ".gif",
+	false: ".png",
+}
+
+type bttvEmote struct {
+	id        string
+	keywords  []string
+	emojiList []string
+}
+
+type bttvResponse struct {
+	Code      string `json:"code"`
+	ImageType string `json:"imageType"`
+	Animated  bool   `json:"animated"`
+}
+
+func newBTTVEmote(input *EmoteInput, keywords []string) Emote {
+	return &bttvEmote{input.ID, keywords, input.EmojiList}
+}
+
+func isValidBTTVId(id string) bool {
+	return bttvIDRegex.MatchString(id)
+}
+
+func (e *bttvEmote) Download(ctx context.Context) (EmoteData, error) {
+	info, err := e.info(ctx)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to get data for %s: %w", e.id, err)
+	}
+	// older emotes only report the image type
+	isAnimated := info.Animated || info.ImageType == "gif"
+
+	extension := extensionsBTTV[isAnimated]
+	url := fmt.Sprintf("%s/emote/%s/3x%s", cdnURLBTTV(), e.id, extension)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed creating request: %w", err)
+	}
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  sourceBTTV.Client,
+		Retries: sourceBTTV.Retries,
+	}
+
+	data, err := retrier.Download(retryParams)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to download emote %s: %w", e.id, err)
+	}
+
+	return EmoteData{
+		File:     data,
+		Animated: isAnimated,
+		Name:     info.Code,
+	}, nil
+}
+
+func (e *bttvEmote) ID() string {
+	return e.id
+}
+
+func (e *bttvEmote) Keywords() []string {
+	return e.keywords
+}
+
+func (e *bttvEmote) EmojiList() []string {
+	return e.emojiList
+}
+
+func (e *bttvEmote) String() string {
+	return fmt.Sprintf("bttv:%s", e.id)
+}
+
+func (e *bttvEmote) info(ctx context.Context) (*bttvResponse, error) {
+	url := fmt.Sprintf("%s/emotes/%s", apiURLBTTV(), e.id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating request: %w", err)
+	}
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  sourceBTTV.Client,
+		Retries: sourceBTTV.Retries,
+	}
+	resp, err := retrier.RequestWithCallback(
+		ctx, retryParams, animatedRespCallback,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch emote info: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var info bttvResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to parse bttv response")
+	}
+
+	return &info, nil
+}
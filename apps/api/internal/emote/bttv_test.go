@@ -0,0 +1,92 @@
+package emote
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fake bttv emotes by id, served by serveBTTV
+var emotesBTTV = map[string]string{
+	"5f1b0186cf6d2144653d2970": `{"code":"catJAM","imageType":"gif","animated":true}`,
+	"56e9f494fff3cc5c35e5287e": `{"code":"monkaS","imageType":"png","animated":false}`,
+	"54fa8f1401e468494b85b537": `{"code":"KEKW","imageType":"gif"}`,
+}
+
+// serveBTTV answers like the api under /3 and like the cdn under /emote,
+// the cdn returns the path so tests can see which file was picked
+func serveBTTV(w http.ResponseWriter, r *http.Request) {
+	if id, ok := strings.CutPrefix(r.URL.Path, "/3/emotes/"); ok {
+		info, ok := emotesBTTV[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(info))
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/emote/") {
+		w.Write([]byte(r.URL.Path))
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func TestBTTVDownload(t *testing.T) {
+	tests := []struct {
+		id       string
+		file     string
+		animated bool
+		name     string
+	}{
+		{"5f1b0186cf6d2144653d2970", "/emote/5f1b0186cf6d2144653d2970/3x.gif", true, "catJAM"},
+		{"56e9f494fff3cc5c35e5287e", "/emote/56e9f494fff3cc5c35e5287e/3x.png", false, "monkaS"},
+		// older emotes only have the image type
+		{"54fa8f1401e468494b85b537", "/emote/54fa8f1401e468494b85b537/3x.gif", true, "KEKW"},
+	}
+
+	for _, tt := range tests {
+		e := newBTTVEmote(&EmoteInput{ID: tt.id}, nil)
+		data, err := e.Download(context.Background())
+		if err != nil {
+			t.Errorf("%s: %v", tt.id, err)
+			continue
+		}
+		if string(data.File) != tt.file {
+			t.Errorf("%s: downloaded %q, want %q", tt.id, data.File, tt.file)
+		}
+		if data.Animated != tt.animated {
+			t.Errorf("%s: animated = %v, want %v", tt.id, data.Animated, tt.animated)
+		}
+		if data.Name != tt.name {
+			t.Errorf("%s: name = %q, want %q", tt.id, data.Name, tt.name)
+		}
+	}
+}
+
+func TestBTTVDownloadMissing(t *testing.T) {
+	e := newBTTVEmote(&EmoteInput{ID: "000000000000000000000000"}, nil)
+	if _, err := e.Download(context.Background()); err == nil {
+		t.Error("Download of a missing emote succeeded")
+	}
+}
+
+func TestIsValidBTTVId(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"5f1b0186cf6d2144653d2970", true},
+		{"5F1B0186CF6D2144653D2970", false},
+		{"5f1b0186cf6d2144653d297", false},
+		{"5f1b0186cf6d2144653d2970a", false},
+		{"../../5f1b0186cf6d214465", false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidBTTVId(tt.id); got != tt.want {
+			t.Errorf("isValidBTTVId(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
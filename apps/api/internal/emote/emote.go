@@ -3,10 +3,12 @@ package emote
 import (
 	"context"
 	"fmt"
+	"strings"
 )
 
 const maxKeywords = 20 - 5 // the tg limit is 20, we need 2, 5 is just to be safe
 const maxEmojis = 20
+const maxKeywordLength = 64
 
 type Emote interface {
 	Download(context.Context) (EmoteData, error)
@@ -18,7 +20,16 @@ type Emote interface {
 
 type EmoteData struct {
 	Animated bool
-	File     []byte
+	// lottie stickers from telegram, they skip resizing
+	TGS bool
+	// the file is the text of a text-only sticker, it's rendered instead
+	// of resized
+	Text bool
+	File []byte
+	// metadata from the source, used by Autofill
+	Name   string
+	Tags   []string
+	Emojis []string
 }
 
 type EmoteInput struct {
@@ -26,27 +37,77 @@ type EmoteInput struct {
 	ID        string   `json:"id"`
 	Keywords  []string `json:"keywords"`
 	EmojiList []string `json:"emoji_list"`
+	// only for 7tv-set, see ExpandInputs
+	Filter *SetFilter `json:"filter,omitempty"`
+	// file contents for the "upload" source, ID is the form field name
+	Upload []byte `json:"-"`
+	Options
 }
 
-func (e *EmoteInput) ToEmote() (Emote, error) {
+// Validate checks the input without downloading anything, so requests
+// can be rejected before they are queued
+func (e *EmoteInput) Validate() error {
 	if len(e.Keywords) > maxKeywords {
-		return nil, fmt.Errorf("max %d keywords is supported", maxKeywords)
+		return fmt.Errorf("max %d keywords is supported", maxKeywords)
 	}
 
 	if len(e.EmojiList) > maxKeywords {
-		return nil, fmt.Errorf("max %d emojis is supported", maxEmojis)
+		return fmt.Errorf("max %d emojis is supported", maxEmojis)
+	}
+
+	if err := e.Options.Validate(); err != nil {
+		return err
+	}
+
+	src, err := lookupSource(e.Source)
+	if err != nil {
+		return err
+	}
+	if !src.Validate(e.ID) {
+		return fmt.Errorf("id %s invalid", e.ID)
+	}
+	if e.Filter != nil && src != source7TVSet {
+		return fmt.Errorf("filter is only supported for %s", source7TVSet.Name)
+	}
+	return nil
+}
+
+func (e *EmoteInput) ToEmote() (Emote, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	src, err := lookupSource(e.Source)
+	if err != nil {
+		return nil, err
+	}
+	if src.New == nil {
+		return nil, fmt.Errorf("%s %s was not expanded", e.Source, e.ID)
 	}
 
 	metaKeywords := append(append([]string{}, e.Keywords...), e.Source)
-	switch e.Source {
-	case "7tv":
-		if !isValid7TVId(e.ID) {
-			return nil, fmt.Errorf("id %s invalid", e.ID)
+	return src.New(e, metaKeywords), nil
+}
+
+// appendKeywords adds keywords found in emote metadata, skipping duplicates,
+// there's room for the user keywords and the source
+func appendKeywords(keywords []string, extra []string) []string {
+	seen := make(map[string]bool, len(keywords))
+	for _, keyword := range keywords {
+		seen[strings.ToLower(keyword)] = true
+	}
+
+	for _, keyword := range extra {
+		if len(keywords) >= maxKeywords {
+			break
+		}
+		keyword = strings.TrimSpace(keyword)
+		lower := strings.ToLower(keyword)
+		if keyword == "" || len(keyword) > maxKeywordLength || seen[lower] {
+			continue
 		}
-		return &sevenTVEmote{e.ID, metaKeywords, e.EmojiList}, nil
-	case "tenor":
-		return &tenorEmote{e.ID, metaKeywords, e.EmojiList}, nil
-	default:
-		return nil, fmt.Errorf("unsupported source %s", e.Source)
+		seen[lower] = true
+		keywords = append(keywords, keyword)
 	}
+	return keywords
 }
@@ -0,0 +1,155 @@
+package emote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
+)
+
+// largest first, not every emote has all of them
+var scalesFFZ = []string{"4", "2", "1"}
+
+var sourceFFZ = register(&Source{
+	Name:     "ffz",
+	New:      newFFZEmote,
+	Validate: isValidFFZId,
+	Client: &http.Client{
+		Timeout: 10 * time.Second,
+	},
+	Retries: 3,
+	Constraints: Constraints{
+		IDFormat: "numeric emote id",
+		Animated: true,
+	},
+})
+
+type ffzEmote struct {
+	id        string
+	keywords  []string
+	emojiList []string
+}
+
+type ffzImage struct {
+	url      string
+	animated bool
+	name     string
+}
+
+type ffzResponse struct {
+	Emote struct {
+		Name     string            `json:"name"`
+		URLs     map[string]string `json:"urls"`
+		Animated map[string]string `json:"animated"`
+	} `json:"emote"`
+}
+
+func newFFZEmote(input *EmoteInput, keywords []string) Emote {
+	return &ffzEmote{input.ID, keywords, input.EmojiList}
+}
+
+func isValidFFZId(id string) bool {
+	n, err := strconv.Atoi(id)
+	return err == nil && n > 0
+}
+
+func (e *ffzEmote) Download(ctx context.Context) (EmoteData, error) {
+	image, err := e.largestImage(ctx)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to get data for %s: %w", e.id, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, image.url, nil)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed creating request: %w", err)
+	}
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  sourceFFZ.Client,
+		Retries: sourceFFZ.Retries,
+	}
+
+	data, err := retrier.Download(retryParams)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to download emote %s: %w", e.id, err)
+	}
+
+	return EmoteData{
+		File:     data,
+		Animated: image.animated,
+		Name:     image.name,
+	}, nil
+}
+
+func (e *ffzEmote) ID() string {
+	return e.id
+}
+
+func (e *ffzEmote) Keywords() []string {
+	return e.keywords
+}
+
+func (e *ffzEmote) EmojiList() []string {
+	return e.emojiList
+}
+
+func (e *ffzEmote) String() string {
+	return fmt.Sprintf("ffz:%s", e.id)
+}
+
+func (e *ffzEmote) largestImage(ctx context.Context) (ffzImage, error) {
+	url := fmt.Sprintf("https://api.frankerfacez.com/v1/emote/%s", e.id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ffzImage{}, fmt.Errorf("failed creating request: %w", err)
+	}
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  sourceFFZ.Client,
+		Retries: sourceFFZ.Retries,
+	}
+	resp, err := retrier.RequestWithCallback(
+		ctx, retryParams, animatedRespCallback,
+	)
+	if err != nil {
+		return ffzImage{}, fmt.Errorf("failed to fetch emote info: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var info ffzResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return ffzImage{}, fmt.Errorf("failed to parse ffz response")
+	}
+
+	// animated emotes also have static urls, the animated ones are webp
+	// and the CDN serves a gif when asked for it
+	name := info.Emote.Name
+	if url, ok := pickFFZScale(info.Emote.Animated); ok {
+		return ffzImage{url + ".gif", true, name}, nil
+	}
+	if url, ok := pickFFZScale(info.Emote.URLs); ok {
+		return ffzImage{url, false, name}, nil
+	}
+
+	return ffzImage{}, fmt.Errorf("emote has no images")
+}
+
+func pickFFZScale(urls map[string]string) (string, bool) {
+	for _, scale := range scalesFFZ {
+		url, ok := urls[scale]
+		if !ok || url == "" {
+			continue
+		}
+		// the api used to return protocol-relative urls
+		if strings.HasPrefix(url, "//") {
+			url = "https:" + url
+		}
+		return url, true
+	}
+	return "", false
+}
@@ -0,0 +1,66 @@
+package emote
+
+import "testing"
+
+func TestPickFFZScale(t *testing.T) {
+	tests := []struct {
+		name string
+		urls map[string]string
+		want string
+		ok   bool
+	}{
+		{
+			"largest",
+			map[string]string{"1": "https://cdn.frankerfacez.com/emote/1/1", "4": "https://cdn.frankerfacez.com/emote/1/4"},
+			"https://cdn.frankerfacez.com/emote/1/4",
+			true,
+		},
+		{
+			"falls back to 2",
+			map[string]string{"1": "https://cdn.frankerfacez.com/emote/1/1", "2": "https://cdn.frankerfacez.com/emote/1/2"},
+			"https://cdn.frankerfacez.com/emote/1/2",
+			true,
+		},
+		{
+			"empty url is skipped",
+			map[string]string{"4": "", "1": "https://cdn.frankerfacez.com/emote/1/1"},
+			"https://cdn.frankerfacez.com/emote/1/1",
+			true,
+		},
+		{
+			"protocol-relative",
+			map[string]string{"1": "//cdn.frankerfacez.com/emote/1/1"},
+			"https://cdn.frankerfacez.com/emote/1/1",
+			true,
+		},
+		{"nothing", map[string]string{}, "", false},
+		{"nil", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := pickFFZScale(tt.urls)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: pickFFZScale = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsValidFFZId(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"128054", true},
+		{"1", true},
+		{"0", false},
+		{"-5", false},
+		{"12a", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidFFZId(tt.id); got != tt.want {
+			t.Errorf("isValidFFZId(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package emote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// the config is loaded once and exits without the required env,
+// bttv is pointed at a fake api since its urls come from the config
+func TestMain(m *testing.M) {
+	bttv := httptest.NewServer(http.HandlerFunc(serveBTTV))
+	env := map[string]string{
+		"BTTV_API_URL":     bttv.URL + "/3/",
+		"BTTV_CDN_URL":     bttv.URL,
+		"SECRET_KEY":       "test-secret-key-that-is-long-enough",
+		"DOWNLOAD_RETRIES": "0",
+		"DOMAIN":           "example.com",
+		"TELEGRAM_TOKEN":   "123:token",
+		"BOT_NAME":         "test_bot",
+	}
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+	code := m.Run()
+	bttv.Close()
+	os.Exit(code)
+}
@@ -0,0 +1,419 @@
+package emote
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	StaticFormatAuto = ""
+	StaticFormatPNG  = "png"
+	StaticFormatWebP = "webp"
+)
+
+const (
+	// scale the longest side, the other one is smaller
+	FitContain = ""
+	// scale the shortest side and crop the middle to a square
+	FitCover = "cover"
+	// contain and add transparent padding up to a square
+	FitPad = "pad"
+	// cut out Crop and contain it
+	FitCrop = "crop"
+)
+
+const (
+	// animations longer than 3 seconds are cut
+	TimingCut = ""
+	// animations longer than 3 seconds are sped up to fit
+	TimingSpeed = "speed"
+)
+
+const (
+	// nearest neighbor for pixel art, lanczos otherwise
+	ResampleAuto     = ""
+	ResampleLanczos  = "lanczos"
+	ResampleBicubic  = "bicubic"
+	ResampleBilinear = "bilinear"
+	// pixel art is scaled by whole multiples to keep the pixels square
+	ResampleNearest = "nearest"
+)
+
+const (
+	TransformRotate    = "rotate"
+	TransformMirror    = "mirror"
+	TransformCrop      = "crop"
+	TransformGrayscale = "grayscale"
+	TransformHue       = "hue"
+	// brightness and contrast
+	TransformAdjust = "adjust"
+)
+
+const (
+	MirrorHorizontal = ""
+	MirrorVertical   = "vertical"
+)
+
+const (
+	EffectShake   = "shake"
+	EffectSpin    = "spin"
+	EffectPulse   = "pulse"
+	EffectBounce  = "bounce"
+	EffectRainbow = "rainbow"
+	// oversaturated, oversharpened and noisy
+	EffectDeepFry = "deepfry"
+)
+
+const (
+	FontBold    = ""
+	FontRegular = "regular"
+	FontMono    = "mono"
+)
+
+const (
+	CaptionTop    = ""
+	CaptionBottom = "bottom"
+)
+
+const (
+	maxTrimMargin    = 128
+	maxOutlineWidth  = 32
+	maxCaptionLength = 100
+	maxTransforms    = 16
+)
+
+// Options control how the resize package processes an emote,
+// they are embedded in EmoteInput and flattened in JSON
+type Options struct {
+	// makes a solid background transparent, runs before the transforms
+	RemoveBackground *BackgroundKey `json:"remove_background,omitempty"`
+	// applied in order to the original emote before anything else
+	Transforms []Transform `json:"transforms,omitempty"`
+	// png is used when it fits, webp otherwise
+	StaticFormat string `json:"static_format,omitempty"`
+	Fit          string `json:"fit,omitempty"`
+	// only used with FitCrop
+	Crop *CropRect `json:"crop,omitempty"`
+	// transparent borders are cut off unless this is set,
+	// explicit crops are never trimmed
+	NoTrim bool `json:"no_trim,omitempty"`
+	// pixels of the original emote kept around the visible part
+	TrimMargin int `json:"trim_margin,omitempty"`
+	// nil uses the pack outline, a zero width disables it
+	Outline *Outline `json:"outline,omitempty"`
+	// scaling filter, pixel art is detected when empty
+	Resample string `json:"resample,omitempty"`
+	// meme-style text over the emote
+	Caption *Caption `json:"caption,omitempty"`
+	// style of text-only stickers, white with a black stroke when nil
+	TextStyle *TextStyle `json:"text_style,omitempty"`
+	// turn a static emote into a looping video, applied in order.
+	// Animated emotes keep their own animation
+	Effects []string `json:"effects,omitempty"`
+
+	// the timing options only apply to animated emotes
+	Timing string `json:"timing,omitempty"`
+	// seconds of the original animation, a zero end is the last frame
+	TrimStart float64 `json:"trim_start,omitempty"`
+	TrimEnd   float64 `json:"trim_end,omitempty"`
+	Reverse   bool    `json:"reverse,omitempty"`
+	// play forwards and then backwards
+	Boomerang bool `json:"boomerang,omitempty"`
+}
+
+// Outline is a stroke around the visible part of the emote
+type Outline struct {
+	// pixels on a 512px sticker, scaled down for emoji
+	Width int `json:"width"`
+	// hex rrggbb, white when empty
+	Color  string `json:"color,omitempty"`
+	Shadow bool   `json:"shadow,omitempty"`
+}
+
+// BackgroundKey is the color made transparent by RemoveBackground
+type BackgroundKey struct {
+	// hex rrggbb, detected from the border of the emote when empty
+	Color string `json:"color,omitempty"`
+	// percent of the color distance still counted as background,
+	// 0 uses a default that covers jpeg noise
+	Tolerance int `json:"tolerance,omitempty"`
+}
+
+// Transform is a step of the per-sticker edit, Type picks the fields used
+type Transform struct {
+	Type string `json:"type"`
+	// clockwise, 90, 180 or 270
+	Degrees int    `json:"degrees,omitempty"`
+	Axis    string `json:"axis,omitempty"`
+	// in pixels of the emote as the previous transforms left it
+	Rect *CropRect `json:"rect,omitempty"`
+	// degrees on the color wheel
+	Hue float64 `json:"hue,omitempty"`
+	// percentages between -100 and 100
+	Brightness float64 `json:"brightness,omitempty"`
+	Contrast   float64 `json:"contrast,omitempty"`
+}
+
+// TextStyle is shared by captions and text-only stickers
+type TextStyle struct {
+	Font string `json:"font,omitempty"`
+	// hex rrggbb, white when empty
+	Color string `json:"color,omitempty"`
+	// hex rrggbb, black when empty
+	StrokeColor string `json:"stroke_color,omitempty"`
+}
+
+// Caption is wrapped and sized to fit the top or bottom of the emote
+type Caption struct {
+	Text     string `json:"text"`
+	Position string `json:"position,omitempty"`
+	TextStyle
+}
+
+// CropRect is in pixels of the original emote
+type CropRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (o *Options) Validate() error {
+	switch o.StaticFormat {
+	case StaticFormatAuto, StaticFormatPNG, StaticFormatWebP:
+	default:
+		return fmt.Errorf("unsupported static format %s", o.StaticFormat)
+	}
+
+	switch o.Fit {
+	case FitContain, FitCover, FitPad:
+		if o.Crop != nil {
+			return fmt.Errorf("crop is only used with fit %s", FitCrop)
+		}
+	case FitCrop:
+		if o.Crop == nil {
+			return fmt.Errorf("fit %s needs a crop rectangle", FitCrop)
+		}
+		if err := o.Crop.validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported fit %s", o.Fit)
+	}
+
+	if o.TrimMargin < 0 || o.TrimMargin > maxTrimMargin {
+		return fmt.Errorf("trim margin has to be between 0 and %d", maxTrimMargin)
+	}
+
+	switch o.Resample {
+	case ResampleAuto, ResampleLanczos, ResampleBicubic, ResampleBilinear, ResampleNearest:
+	default:
+		return fmt.Errorf("unsupported resample filter %s", o.Resample)
+	}
+
+	switch o.Timing {
+	case TimingCut, TimingSpeed:
+	default:
+		return fmt.Errorf("unsupported timing %s", o.Timing)
+	}
+	if o.TrimStart < 0 || o.TrimEnd < 0 {
+		return fmt.Errorf("trim times can't be negative")
+	}
+	if o.TrimEnd != 0 && o.TrimEnd <= o.TrimStart {
+		return fmt.Errorf("trim end has to be after the start")
+	}
+
+	if o.RemoveBackground != nil {
+		if err := o.RemoveBackground.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if len(o.Transforms) > maxTransforms {
+		return fmt.Errorf("max %d transforms are supported", maxTransforms)
+	}
+	for i := range o.Transforms {
+		if err := o.Transforms[i].Validate(); err != nil {
+			return fmt.Errorf("transform %d: %w", i, err)
+		}
+	}
+
+	seen := make(map[string]bool, len(o.Effects))
+	for _, effect := range o.Effects {
+		switch effect {
+		case EffectShake, EffectSpin, EffectPulse, EffectBounce,
+			EffectRainbow, EffectDeepFry:
+		default:
+			return fmt.Errorf("unsupported effect %s", effect)
+		}
+		if seen[effect] {
+			return fmt.Errorf("effect %s is used twice", effect)
+		}
+		seen[effect] = true
+	}
+
+	if o.Caption != nil {
+		if err := o.Caption.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.TextStyle != nil {
+		if err := o.TextStyle.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Outline != nil {
+		return o.Outline.Validate()
+	}
+	return nil
+}
+
+func (o *Outline) Validate() error {
+	if o.Width < 0 || o.Width > maxOutlineWidth {
+		return fmt.Errorf("outline width has to be between 0 and %d", maxOutlineWidth)
+	}
+	if _, err := parseHexColor(o.Color); err != nil {
+		return fmt.Errorf("invalid outline color %s", o.Color)
+	}
+	return nil
+}
+
+func (k *BackgroundKey) Validate() error {
+	if k.Tolerance < 0 || k.Tolerance > 100 {
+		return fmt.Errorf("background tolerance has to be between 0 and 100")
+	}
+	if _, err := parseHexColor(k.Color); err != nil {
+		return fmt.Errorf("invalid background color %s", k.Color)
+	}
+	return nil
+}
+
+// Key is the explicit key color, false when it has to be detected
+func (k *BackgroundKey) Key() (color.NRGBA, bool) {
+	if k.Color == "" {
+		return color.NRGBA{}, false
+	}
+	c, _ := parseHexColor(k.Color)
+	return c, true
+}
+
+func (t *Transform) Validate() error {
+	switch t.Type {
+	case TransformRotate:
+		if t.Degrees != 90 && t.Degrees != 180 && t.Degrees != 270 {
+			return fmt.Errorf("rotation has to be 90, 180 or 270 degrees")
+		}
+	case TransformMirror:
+		if t.Axis != MirrorHorizontal && t.Axis != MirrorVertical {
+			return fmt.Errorf("unsupported mirror axis %s", t.Axis)
+		}
+	case TransformCrop:
+		if t.Rect == nil {
+			return fmt.Errorf("crop needs a rectangle")
+		}
+		return t.Rect.validate()
+	case TransformGrayscale:
+	case TransformHue:
+		if t.Hue < -180 || t.Hue > 180 {
+			return fmt.Errorf("hue shift has to be between -180 and 180")
+		}
+	case TransformAdjust:
+		if t.Brightness < -100 || t.Brightness > 100 ||
+			t.Contrast < -100 || t.Contrast > 100 {
+			return fmt.Errorf("brightness and contrast have to be between -100 and 100")
+		}
+	default:
+		return fmt.Errorf("unsupported transform %s", t.Type)
+	}
+	return nil
+}
+
+func (s *TextStyle) Validate() error {
+	switch s.Font {
+	case FontBold, FontRegular, FontMono:
+	default:
+		return fmt.Errorf("unsupported font %s", s.Font)
+	}
+	if _, err := parseHexColor(s.Color); err != nil {
+		return fmt.Errorf("invalid text color %s", s.Color)
+	}
+	if _, err := parseHexColor(s.StrokeColor); err != nil {
+		return fmt.Errorf("invalid text stroke color %s", s.StrokeColor)
+	}
+	return nil
+}
+
+// Fill is the letter color, the validated color is assumed
+func (s *TextStyle) Fill() color.NRGBA {
+	c, _ := parseHexColor(s.Color)
+	return c
+}
+
+// Stroke is the outline color of the letters
+func (s *TextStyle) Stroke() color.NRGBA {
+	if s.StrokeColor == "" {
+		return color.NRGBA{0, 0, 0, 255}
+	}
+	c, _ := parseHexColor(s.StrokeColor)
+	return c
+}
+
+func (c *Caption) Validate() error {
+	if strings.TrimSpace(c.Text) == "" {
+		return fmt.Errorf("caption can't be empty")
+	}
+	if utf8.RuneCountInString(c.Text) > maxCaptionLength {
+		return fmt.Errorf("caption can't be longer than %d characters", maxCaptionLength)
+	}
+	switch c.Position {
+	case CaptionTop, CaptionBottom:
+	default:
+		return fmt.Errorf("unsupported caption position %s", c.Position)
+	}
+	return c.TextStyle.Validate()
+}
+
+// Visible reports if the outline changes the emote at all
+func (o *Outline) Visible() bool {
+	return o != nil && (o.Width > 0 || o.Shadow)
+}
+
+// RGB is the stroke color, the validated color is assumed
+func (o *Outline) RGB() color.NRGBA {
+	c, _ := parseHexColor(o.Color)
+	return c
+}
+
+func parseHexColor(s string) (color.NRGBA, error) {
+	if s == "" {
+		return color.NRGBA{255, 255, 255, 255}, nil
+	}
+
+	rgb, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+	if len(rgb) != 3 {
+		return color.NRGBA{}, fmt.Errorf("expected 6 hex digits")
+	}
+	return color.NRGBA{rgb[0], rgb[1], rgb[2], 255}, nil
+}
+
+// Trims reports if the transparent border is cut off before resizing
+func (o *Options) Trims() bool {
+	return !o.NoTrim && o.Fit != FitCrop
+}
+
+func (c *CropRect) validate() error {
+	if c.X < 0 || c.Y < 0 {
+		return fmt.Errorf("crop starts outside of the emote")
+	}
+	if c.Width <= 0 || c.Height <= 0 {
+		return fmt.Errorf("crop has to be at least 1x1")
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package emote
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
+)
+
+// Source is a registered emote provider
+type Source struct {
+	Name string
+	// New builds the emote, keywords already contain the source name,
+	// nil for sources that are expanded before processing
+	New      func(input *EmoteInput, keywords []string) Emote
+	Validate func(id string) bool
+	Client   *http.Client
+	// Retries is how many times a failed download is retried
+	Retries     int
+	Constraints Constraints
+	// the same id can point to different files, like upload field names
+	NoCache bool
+	// Mutable matches the ids that can point to a different file later,
+	// like a position in a set that is edited. Other ids are cached
+	Mutable func(id string) bool
+}
+
+// Constraints are what clients need to know to build a valid EmoteInput
+type Constraints struct {
+	IDFormat    string `json:"id_format"`
+	MaxFileSize int    `json:"max_file_size,omitempty"`
+	Animated    bool   `json:"animated"`
+	Expands     bool   `json:"expands,omitempty"`
+}
+
+type SourceInfo struct {
+	Name string `json:"name"`
+	Constraints
+}
+
+var sources = make(map[string]*Source)
+
+func register(src *Source) *Source {
+	if _, exists := sources[src.Name]; exists {
+		panic(fmt.Sprintf("emote source %s registered twice", src.Name))
+	}
+	sources[src.Name] = src
+	return src
+}
+
+// empty config means everything is enabled
+func isEnabled(name string) bool {
+	enabled := config.Load().EmoteSources()
+	return len(enabled) == 0 || slices.Contains(enabled, name)
+}
+
+func lookupSource(name string) (*Source, error) {
+	src, ok := sources[name]
+	if !ok || !isEnabled(name) {
+		return nil, fmt.Errorf("unsupported source %s", name)
+	}
+	return src, nil
+}
+
+// EnabledSources lists the sources that can be used in requests
+func EnabledSources() []SourceInfo {
+	infos := make([]SourceInfo, 0, len(sources))
+	for name, src := range sources {
+		if !isEnabled(name) {
+			continue
+		}
+		infos = append(infos, SourceInfo{
+			Name:        name,
+			Constraints: src.Constraints,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// Cacheable tells if the download can be reused by other requests
+func (e *EmoteInput) Cacheable() bool {
+	src, ok := sources[e.Source]
+	if !ok || src.NoCache {
+		return false
+	}
+	return src.Mutable == nil || !src.Mutable(e.ID)
+}
+
+func anyID(id string) bool {
+	return id != ""
+}
@@ -0,0 +1,25 @@
+package emote
+
+import "testing"
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		source string
+		id     string
+		want   bool
+	}{
+		{"7tv", "01F6MQ33FG000FFJ97ZB8MWV52", true},
+		{"telegram", "CAACAgIAAxkBAAEBQ2Nk", true},
+		{"telegram", "Animals:3", false},
+		{UploadSource, "file0", false},
+		{"text", "hello", false},
+		{"nope", "id", false},
+	}
+
+	for _, tt := range tests {
+		input := &EmoteInput{Source: tt.source, ID: tt.id}
+		if got := input.Cacheable(); got != tt.want {
+			t.Errorf("%s:%s cacheable = %v, want %v", tt.source, tt.id, got, tt.want)
+		}
+	}
+}
@@ -1,24 +1,56 @@
 package emote
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
 )
 
-const idLength = 26
+const (
+	idLength  = 26
+	gqlURL7TV = "https://api.7tv.app/v4/gql"
+)
 
-var (
-	retries7TV    = config.Load().DownloadRetries()
-	httpClient7TV = &http.Client{
+var source7TV = register(&Source{
+	Name:     "7tv",
+	New:      new7TVEmote,
+	Validate: isValid7TVId,
+	Client: &http.Client{
 		Timeout: 10 * time.Second,
-	}
-)
+	},
+	Retries: 3,
+	Constraints: Constraints{
+		IDFormat: "26 character emote id",
+		Animated: true,
+	},
+})
+
+// formats the resizer can decode, best first
+// gif only has 1 bit alpha, so webp goes before it
+var formats7TV = map[bool][]string{
+	true:  {MediaWebP, MediaGIF, MediaAVIF},
+	false: {MediaPNG, MediaWebP, MediaAVIF},
+}
+
+const emoteQuery7TV = `
+query EmoteImages($id: ID!) {
+  emotes {
+    emote(id: $id) {
+      defaultName
+      images {
+        url
+        mime
+        scale
+        frameCount
+      }
+    }
+  }
+}`
 
 type sevenTVEmote struct {
 	id        string
@@ -26,36 +58,56 @@ type sevenTVEmote struct {
 	emojiList []string
 }
 
+type sevenTVImage struct {
+	URL        string `json:"url"`
+	Mime       string `json:"mime"`
+	Scale      int    `json:"scale"`
+	FrameCount int    `json:"frameCount"`
+}
+
+type sevenTVEmoteInfo struct {
+	DefaultName string         `json:"defaultName"`
+	Images      []sevenTVImage `json:"images"`
+}
+
 type sevenTVResponse struct {
-	Animated bool `json:"animated"`
+	Data struct {
+		Emotes struct {
+			Emote *sevenTVEmoteInfo `json:"emote"`
+		} `json:"emotes"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
 }
 
-func isValid7TVId(id string) bool {
-	return len(id) == idLength // best I can come up with right now
+func new7TVEmote(input *EmoteInput, keywords []string) Emote {
+	return &sevenTVEmote{input.ID, keywords, input.EmojiList}
 }
 
-// I'm assuming there's always a gif and a png
-var extensions = map[bool]string{
-	true:  ".gif",
-	false: ".png",
+func isValid7TVId(id string) bool {
+	return len(id) == idLength // best I can come up with right now
 }
 
 func (e *sevenTVEmote) Download(ctx context.Context) (EmoteData, error) {
-	isAnimated, err := e.isAnimated(ctx)
+	info, err := e.info(ctx)
 	if err != nil {
 		return EmoteData{}, fmt.Errorf("failed to get data for %s: %w", e.id, err)
 	}
 
-	extension := extensions[isAnimated]
-	url := fmt.Sprintf("https://cdn.7tv.app/emote/%s/4x%s", e.id, extension)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	image, err := pick7TVImage(info.Images)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("emote %s: %w", e.id, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, image.URL, nil)
 	if err != nil {
 		return EmoteData{}, fmt.Errorf("failed creating request: %w", err)
 	}
 	retryParams := &retrier.RetryParams{
 		Request: req,
-		Client:  httpClient7TV,
-		Retries: retries7TV,
+		Client:  source7TV.Client,
+		Retries: source7TV.Retries,
 	}
 
 	data, err := retrier.Download(retryParams)
@@ -65,7 +117,8 @@ func (e *sevenTVEmote) Download(ctx context.Context) (EmoteData, error) {
 
 	return EmoteData{
 		File:     data,
-		Animated: isAnimated,
+		Animated: image.FrameCount > 1,
+		Name:     info.DefaultName,
 	}, nil
 }
 
@@ -85,33 +138,77 @@ func (e *sevenTVEmote) String() string {
 	return fmt.Sprintf("7tv:%s", e.id)
 }
 
-func (e *sevenTVEmote) isAnimated(ctx context.Context) (bool, error) {
-	// Currently using an old api, if it's deprecated...
-	// We'll have to deal with GraphQL...
-	url := fmt.Sprintf("https://7tv.io/v3/emotes/%s", e.id)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (e *sevenTVEmote) info(ctx context.Context) (*sevenTVEmoteInfo, error) {
+	body, err := json.Marshal(map[string]any{
+		"operationName": "EmoteImages",
+		"query":         emoteQuery7TV,
+		"variables":     map[string]string{"id": e.id},
+	})
 	if err != nil {
-		return false, fmt.Errorf("failed creating request: %w", err)
+		return nil, fmt.Errorf("failed to encode query: %w", err)
 	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, gqlURL7TV, bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
 	retryParams := &retrier.RetryParams{
 		Request: req,
-		Client:  httpClient7TV,
-		Retries: retries7TV,
+		Client:  source7TV.Client,
+		Retries: source7TV.Retries,
 	}
 	resp, err := retrier.RequestWithCallback(
 		ctx, retryParams, animatedRespCallback,
 	)
 	if err != nil {
-		return false, fmt.Errorf("failed to determine animated status: %v", err)
+		return nil, fmt.Errorf("failed to fetch emote images: %v", err)
 	}
 	defer resp.Body.Close()
 
 	var info sevenTVResponse
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return false, fmt.Errorf("failed to parse 7tv response")
+		return nil, fmt.Errorf("failed to parse 7tv response")
+	}
+	if len(info.Errors) > 0 {
+		return nil, fmt.Errorf("7tv error: %s", info.Errors[0].Message)
+	}
+	if info.Data.Emotes.Emote == nil {
+		return nil, fmt.Errorf("emote does not exist")
+	}
+
+	return info.Data.Emotes.Emote, nil
+}
+
+// pick7TVImage takes the best format we can decode, then the biggest scale
+func pick7TVImage(images []sevenTVImage) (sevenTVImage, error) {
+	animated := false
+	for _, image := range images {
+		if image.FrameCount > 1 {
+			animated = true
+			break
+		}
+	}
+
+	for _, mime := range formats7TV[animated] {
+		var best *sevenTVImage
+		for i := range images {
+			image := &images[i]
+			if image.Mime != mime || (image.FrameCount > 1) != animated {
+				continue
+			}
+			if best == nil || image.Scale > best.Scale {
+				best = image
+			}
+		}
+		if best != nil {
+			return *best, nil
+		}
 	}
 
-	return info.Animated, nil
+	return sevenTVImage{}, fmt.Errorf("no decodable image format")
 }
 
 func animatedRespCallback(resp *http.Response) (bool, error) {
@@ -0,0 +1,232 @@
+package emote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
+)
+
+var source7TVSet = register(&Source{
+	Name:     "7tv-set",
+	Validate: isValid7TVId,
+	Retries:  3,
+	Constraints: Constraints{
+		IDFormat: "26 character emote set id",
+		Animated: true,
+		Expands:  true,
+	},
+})
+
+// SetFilter narrows down which emotes of a set are added
+type SetFilter struct {
+	// case-insensitive substring of the emote name
+	Name     string `json:"name,omitempty"`
+	Animated *bool  `json:"animated,omitempty"`
+}
+
+// emotes are fetched in pages, 7tv sets hold up to 1000 emotes
+const (
+	setPageSize7TV = 100
+	maxSetPages7TV = 20
+)
+
+const emoteSetQuery7TV = `
+query EmoteSetEmotes($id: ID!, $page: Int!, $perPage: Int!) {
+  emoteSets {
+    emoteSet(id: $id) {
+      emotes(page: $page, perPage: $perPage) {
+        pageCount
+        items {
+          alias
+          emote {
+            id
+            flags {
+              animated
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// sevenTVSetEmote is an emote as it's named in the set
+type sevenTVSetEmote struct {
+	Alias string `json:"alias"`
+	Emote struct {
+		ID    string `json:"id"`
+		Flags struct {
+			Animated bool `json:"animated"`
+		} `json:"flags"`
+	} `json:"emote"`
+}
+
+type sevenTVSetResponse struct {
+	Data struct {
+		EmoteSets struct {
+			EmoteSet *struct {
+				Emotes struct {
+					PageCount int               `json:"pageCount"`
+					Items     []sevenTVSetEmote `json:"items"`
+				} `json:"emotes"`
+			} `json:"emoteSet"`
+		} `json:"emoteSets"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// ExpandInputs replaces every 7tv-set input with the emotes of that set,
+// other inputs are kept in place. limit is how many stickers the pack
+// can take, it depends on the sticker type
+func ExpandInputs(
+	ctx context.Context,
+	inputs []EmoteInput,
+	limit int,
+) ([]EmoteInput, error) {
+	expanded := make([]EmoteInput, 0, len(inputs))
+	for _, input := range inputs {
+		if input.Source != source7TVSet.Name {
+			expanded = append(expanded, input)
+			continue
+		}
+
+		if _, err := lookupSource(input.Source); err != nil {
+			return nil, err
+		}
+		setEmotes, err := expandSet(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand set %s: %w", input.ID, err)
+		}
+		expanded = append(expanded, setEmotes...)
+	}
+
+	if len(expanded) > limit {
+		return nil, fmt.Errorf(
+			"pack has %d emotes, max %d is supported",
+			len(expanded),
+			limit,
+		)
+	}
+
+	return expanded, nil
+}
+
+func expandSet(ctx context.Context, input EmoteInput) ([]EmoteInput, error) {
+	if !source7TVSet.Validate(input.ID) {
+		return nil, fmt.Errorf("id %s invalid", input.ID)
+	}
+
+	setEmotes, err := fetch7TVSet(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var emotes []EmoteInput
+	for _, e := range setEmotes {
+		if !input.Filter.matches(e.Alias, e.Emote.Flags.Animated) {
+			continue
+		}
+
+		keywords := append([]string{e.Alias}, input.Keywords...)
+		if len(keywords) > maxKeywords {
+			keywords = keywords[:maxKeywords]
+		}
+		emotes = append(emotes, EmoteInput{
+			Source:    source7TV.Name,
+			ID:        e.Emote.ID,
+			Keywords:  keywords,
+			EmojiList: input.EmojiList,
+			Options:   input.Options,
+		})
+	}
+
+	if len(emotes) == 0 {
+		return nil, fmt.Errorf("no emotes left after filtering")
+	}
+	return emotes, nil
+}
+
+func (f *SetFilter) matches(name string, animated bool) bool {
+	if f == nil {
+		return true
+	}
+	if f.Animated != nil && *f.Animated != animated {
+		return false
+	}
+	return strings.Contains(strings.ToLower(name), strings.ToLower(f.Name))
+}
+
+func fetch7TVSet(ctx context.Context, id string) ([]sevenTVSetEmote, error) {
+	var emotes []sevenTVSetEmote
+	for page := 1; page <= maxSetPages7TV; page++ {
+		items, pageCount, err := fetch7TVSetPage(ctx, id, page)
+		if err != nil {
+			return nil, err
+		}
+		emotes = append(emotes, items...)
+		if page >= pageCount {
+			break
+		}
+	}
+	return emotes, nil
+}
+
+func fetch7TVSetPage(
+	ctx context.Context,
+	id string,
+	page int,
+) ([]sevenTVSetEmote, int, error) {
+	body, err := json.Marshal(map[string]any{
+		"operationName": "EmoteSetEmotes",
+		"query":         emoteSetQuery7TV,
+		"variables": map[string]any{
+			"id":      id,
+			"page":    page,
+			"perPage": setPageSize7TV,
+		},
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, gqlURL7TV, bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  source7TV.Client,
+		Retries: source7TVSet.Retries,
+	}
+	resp, err := retrier.RequestWithCallback(
+		ctx, retryParams, animatedRespCallback,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch emote set: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var set sevenTVSetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse 7tv set response")
+	}
+	if len(set.Errors) > 0 {
+		return nil, 0, fmt.Errorf("7tv error: %s", set.Errors[0].Message)
+	}
+	if set.Data.EmoteSets.EmoteSet == nil {
+		return nil, 0, fmt.Errorf("emote set does not exist")
+	}
+
+	emotes := set.Data.EmoteSets.EmoteSet.Emotes
+	return emotes.Items, emotes.PageCount, nil
+}
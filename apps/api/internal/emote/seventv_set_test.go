@@ -0,0 +1,76 @@
+package emote
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExpandInputsLimit(t *testing.T) {
+	inputs := make([]EmoteInput, 150)
+	for i := range inputs {
+		inputs[i] = EmoteInput{Source: "7tv", ID: "01F6MQ33FG000FFJ97ZB8MWV52"}
+	}
+
+	tests := []struct {
+		limit int
+		ok    bool
+	}{
+		{120, false},
+		{150, true},
+		{200, true},
+	}
+
+	for _, tt := range tests {
+		_, err := ExpandInputs(context.Background(), inputs, tt.limit)
+		if got := err == nil; got != tt.ok {
+			t.Errorf("limit %d: err = %v, want ok %v", tt.limit, err, tt.ok)
+		}
+	}
+}
+
+func TestSetFilter(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		filter   *SetFilter
+		name     string
+		animated bool
+		want     bool
+	}{
+		{nil, "KEKW", false, true},
+		{&SetFilter{Name: "kek"}, "KEKW", false, true},
+		{&SetFilter{Name: "pog"}, "KEKW", false, false},
+		{&SetFilter{Animated: &yes}, "catJAM", true, true},
+		{&SetFilter{Animated: &yes}, "KEKW", false, false},
+		{&SetFilter{Name: "cat", Animated: &no}, "catJAM", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.matches(tt.name, tt.animated); got != tt.want {
+			t.Errorf("%+v matches(%s, %v) = %v, want %v",
+				tt.filter, tt.name, tt.animated, got, tt.want)
+		}
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	filter := &SetFilter{Name: "kek"}
+	tests := []struct {
+		name  string
+		input EmoteInput
+		ok    bool
+	}{
+		{"emote", EmoteInput{Source: "7tv", ID: "01F6MQ33FG000FFJ97ZB8MWV52"}, true},
+		{"set", EmoteInput{Source: "7tv-set", ID: "01F6MQ33FG000FFJ97ZB8MWV52"}, true},
+		{"filtered set", EmoteInput{Source: "7tv-set", ID: "01F6MQ33FG000FFJ97ZB8MWV52", Filter: filter}, true},
+		{"filter on an emote", EmoteInput{Source: "7tv", ID: "01F6MQ33FG000FFJ97ZB8MWV52", Filter: filter}, false},
+		{"bad set id", EmoteInput{Source: "7tv-set", ID: "nope"}, false},
+		{"unknown source", EmoteInput{Source: "nope", ID: "id"}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.input.Validate()
+		if got := err == nil; got != tt.ok {
+			t.Errorf("%s: Validate = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package emote
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPick7TVImage(t *testing.T) {
+	static := func(mime string, scale int) sevenTVImage {
+		return sevenTVImage{URL: fmt.Sprintf("%s@%d", mime, scale), Mime: mime, Scale: scale, FrameCount: 1}
+	}
+	animated := func(mime string, scale int) sevenTVImage {
+		image := static(mime, scale)
+		image.FrameCount = 30
+		return image
+	}
+
+	tests := []struct {
+		name   string
+		images []sevenTVImage
+		want   string
+		ok     bool
+	}{
+		{
+			"biggest png",
+			[]sevenTVImage{static(MediaPNG, 1), static(MediaPNG, 4), static(MediaPNG, 2)},
+			MediaPNG + "@4",
+			true,
+		},
+		{
+			"png before webp",
+			[]sevenTVImage{static(MediaWebP, 4), static(MediaPNG, 2)},
+			MediaPNG + "@2",
+			true,
+		},
+		{
+			"animated webp before gif",
+			[]sevenTVImage{animated(MediaGIF, 4), animated(MediaWebP, 3)},
+			MediaWebP + "@3",
+			true,
+		},
+		{
+			// 7tv also lists the first frame of animated emotes
+			"static frames of animated emotes are skipped",
+			[]sevenTVImage{static(MediaPNG, 4), animated(MediaGIF, 1)},
+			MediaGIF + "@1",
+			true,
+		},
+		{
+			"avif as a last resort",
+			[]sevenTVImage{animated(MediaAVIF, 4)},
+			MediaAVIF + "@4",
+			true,
+		},
+		{"unknown format", []sevenTVImage{static("image/jxl", 4)}, "", false},
+		{"no images", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := pick7TVImage(tt.images)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: pick7TVImage error = %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if got.URL != tt.want {
+			t.Errorf("%s: pick7TVImage = %q, want %q", tt.name, got.URL, tt.want)
+		}
+	}
+}
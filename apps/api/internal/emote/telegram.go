@@ -0,0 +1,141 @@
+package emote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/telegram"
+)
+
+var (
+	// either a file_id or <set name>:<sticker index>
+	fileIDRegex   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	setIndexRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}:\d+$`)
+
+	webmMagic = []byte("\x1a\x45\xdf\xa3")
+	gzipMagic = []byte("\x1f\x8b")
+)
+
+var sourceTelegram = register(&Source{
+	Name:     "telegram",
+	New:      newTelegramEmote,
+	Validate: isValidTelegramId,
+	// stickers in a set can be moved, file ids are fixed
+	Mutable: setIndexRegex.MatchString,
+	Client: &http.Client{
+		Timeout: 15 * time.Second,
+	},
+	Retries: 3,
+	Constraints: Constraints{
+		IDFormat: "sticker file_id or <set name>:<index>",
+		Animated: true,
+	},
+})
+
+type telegramEmote struct {
+	id        string
+	keywords  []string
+	emojiList []string
+}
+
+func newTelegramEmote(input *EmoteInput, keywords []string) Emote {
+	return &telegramEmote{input.ID, keywords, input.EmojiList}
+}
+
+func isValidTelegramId(id string) bool {
+	return fileIDRegex.MatchString(id) || setIndexRegex.MatchString(id)
+}
+
+func (e *telegramEmote) Download(ctx context.Context) (EmoteData, error) {
+	fileID, emoji, err := e.fileID(ctx)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to find sticker %s: %w", e.id, err)
+	}
+
+	url, err := telegram.FileURL(ctx, fileID, sourceTelegram.Retries)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to get file %s: %w", e.id, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed creating request: %w", err)
+	}
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  sourceTelegram.Client,
+		Retries: sourceTelegram.Retries,
+		Name:    telegram.RedactToken(url),
+	}
+
+	data, err := retrier.Download(retryParams)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to download sticker %s: %w", e.id, err)
+	}
+
+	emoteData := EmoteData{File: data}
+	if emoji != "" {
+		emoteData.Emojis = []string{emoji}
+	}
+
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		// gzipped lottie, can't be converted, telegram takes it as is
+		emoteData.TGS = true
+	case bytes.HasPrefix(data, webmMagic):
+		emoteData.Animated = true
+	case SniffMedia(data) == MediaWebP:
+		emoteData.Animated = false
+	default:
+		return EmoteData{}, fmt.Errorf("sticker %s has unknown format", e.id)
+	}
+	return emoteData, nil
+}
+
+func (e *telegramEmote) ID() string {
+	return e.id
+}
+
+func (e *telegramEmote) Keywords() []string {
+	return e.keywords
+}
+
+func (e *telegramEmote) EmojiList() []string {
+	return e.emojiList
+}
+
+func (e *telegramEmote) String() string {
+	return fmt.Sprintf("telegram:%s", e.id)
+}
+
+// fileID also returns the emoji of the sticker when it comes from a set
+func (e *telegramEmote) fileID(ctx context.Context) (string, string, error) {
+	if !setIndexRegex.MatchString(e.id) {
+		return e.id, "", nil
+	}
+
+	name, indexStr, _ := strings.Cut(e.id, ":")
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid index %s", indexStr)
+	}
+
+	set, err := telegram.FetchPack(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+	if index >= len(set.Stickers) {
+		return "", "", fmt.Errorf(
+			"set %s has only %d stickers", name, len(set.Stickers),
+		)
+	}
+
+	sticker := set.Stickers[index]
+	return sticker.FileID, sticker.Emoji, nil
+}
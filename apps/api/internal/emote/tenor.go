@@ -2,8 +2,13 @@ package emote
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
@@ -11,42 +16,115 @@ import (
 )
 
 var (
-	retriesTenor    = config.Load().DownloadRetries()
-	httpClientTenor = &http.Client{
-		Timeout: 12 * time.Second,
-	}
+	// post ids are numeric, media paths are what the website used to send
+	postIDRegexTenor    = regexp.MustCompile(`^\d+$`)
+	mediaPathRegexTenor = regexp.MustCompile(`^[A-Za-z0-9_-]+/[^/]+$`)
+	// video renditions first, they are smaller and look better
+	formatsTenor = []string{"webm", "mp4", "gif"}
 )
 
+var sourceTenor = register(&Source{
+	Name:     "tenor",
+	New:      newTenorEmote,
+	Validate: isValidTenorId,
+	Client: &http.Client{
+		Timeout: 12 * time.Second,
+	},
+	Retries: 2,
+	Constraints: Constraints{
+		IDFormat: "post id or media path",
+		Animated: true,
+	},
+})
+
 type tenorEmote struct {
 	id        string
 	keywords  []string
 	emojiList []string
 }
 
+type tenorMedia struct {
+	URL      string  `json:"url"`
+	Duration float64 `json:"duration"`
+	// filled from the post, not part of the media object
+	format string
+	title  string
+	tags   []string
+}
+
+type tenorResponse struct {
+	Results []struct {
+		Title              string                `json:"title"`
+		ContentDescription string                `json:"content_description"`
+		Tags               []string              `json:"tags"`
+		MediaFormats       map[string]tenorMedia `json:"media_formats"`
+	} `json:"results"`
+}
+
+func newTenorEmote(input *EmoteInput, keywords []string) Emote {
+	return &tenorEmote{input.ID, keywords, input.EmojiList}
+}
+
+func isValidTenorId(id string) bool {
+	return postIDRegexTenor.MatchString(id) ||
+		mediaPathRegexTenor.MatchString(id)
+}
+
 func (e *tenorEmote) Download(ctx context.Context) (EmoteData, error) {
-	url := fmt.Sprintf("https://media.tenor.com/%s", e.id)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return EmoteData{}, fmt.Errorf("failed creating request: %w", err)
+	// media paths are what older clients sent, they can't be looked up
+	// in the api so the file itself decides and the name is the keyword
+	if !postIDRegexTenor.MatchString(e.id) {
+		url := fmt.Sprintf("https://media.tenor.com/%s", e.id)
+		data, err := e.download(ctx, url)
+		if err != nil {
+			return EmoteData{}, err
+		}
+		isAnimated, err := isAnimatedMedia(data)
+		if err != nil {
+			return EmoteData{}, fmt.Errorf("emote %s: %w", e.id, err)
+		}
+		return EmoteData{
+			File:     data,
+			Animated: isAnimated,
+			Name:     mediaPathName(e.id),
+		}, nil
 	}
-	retryParams := &retrier.RetryParams{
-		Request: req,
-		Client:  httpClientTenor,
-		Retries: retriesTenor,
+
+	media, err := e.resolve(ctx)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to resolve %s: %w", e.id, err)
 	}
-	data, err := retrier.Download(retryParams)
+
+	data, err := e.download(ctx, media.URL)
 	if err != nil {
-		return EmoteData{}, fmt.Errorf(
-			"failed to download emote %s: %w", e.id, err,
-		)
+		return EmoteData{}, err
+	}
+
+	// renditions without a duration are single frames that go down the
+	// static path, gifs are checked since the duration can be missing
+	isAnimated := media.Duration > 0
+	if !isAnimated && media.format == "gif" {
+		isAnimated, err = isAnimatedMedia(data)
+		if err != nil {
+			return EmoteData{}, fmt.Errorf("emote %s: %w", e.id, err)
+		}
 	}
 
 	return EmoteData{
 		File:     data,
-		Animated: true,
+		Animated: isAnimated,
+		Name:     media.title,
+		Tags:     media.tags,
 	}, nil
 }
 
+// mediaPathName turns "<hash>/funny-cat.gif" into "funny cat"
+func mediaPathName(mediaPath string) string {
+	_, file, _ := strings.Cut(mediaPath, "/")
+	name := strings.TrimSuffix(file, path.Ext(file))
+	return strings.ReplaceAll(name, "-", " ")
+}
+
 func (e *tenorEmote) ID() string {
 	return e.id
 }
@@ -62,3 +140,78 @@ func (e *tenorEmote) EmojiList() []string {
 func (e *tenorEmote) String() string {
 	return fmt.Sprintf("tenor:%s", e.id)
 }
+
+func (e *tenorEmote) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating request: %w", err)
+	}
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  sourceTenor.Client,
+		Retries: sourceTenor.Retries,
+	}
+	data, err := retrier.Download(retryParams)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to download emote %s: %w", e.id, err,
+		)
+	}
+	return data, nil
+}
+
+// resolve looks the post up, its title and tags end up in the keywords
+func (e *tenorEmote) resolve(ctx context.Context) (tenorMedia, error) {
+	apiKey := config.Load().TenorAPIKey()
+	if apiKey == "" {
+		return tenorMedia{}, fmt.Errorf("tenor api key is not configured")
+	}
+
+	query := url.Values{}
+	query.Set("ids", e.id)
+	query.Set("media_filter", "webm,mp4,gif")
+	// the key is added last so logs get the url without it
+	postURL := "https://tenor.googleapis.com/v2/posts?" + query.Encode()
+	reqURL := postURL + "&key=" + url.QueryEscape(apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return tenorMedia{}, fmt.Errorf("failed creating request: %w", err)
+	}
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  sourceTenor.Client,
+		Retries: sourceTenor.Retries,
+		Name:    postURL,
+	}
+	resp, err := retrier.RequestWithCallback(
+		ctx, retryParams, animatedRespCallback,
+	)
+	if err != nil {
+		return tenorMedia{}, fmt.Errorf("failed to fetch post: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var info tenorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return tenorMedia{}, fmt.Errorf("failed to parse tenor response")
+	}
+	if len(info.Results) == 0 {
+		return tenorMedia{}, fmt.Errorf("post does not exist")
+	}
+
+	post := info.Results[0]
+	title := post.Title
+	if title == "" {
+		title = post.ContentDescription
+	}
+
+	for _, format := range formatsTenor {
+		if media, ok := post.MediaFormats[format]; ok && media.URL != "" {
+			media.format = format
+			media.title = title
+			media.tags = append([]string{title}, post.Tags...)
+			return media, nil
+		}
+	}
+	return tenorMedia{}, fmt.Errorf("post has no usable media")
+}
@@ -0,0 +1,75 @@
+package emote
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMediaPathName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"AAAAC/funny-cat.gif", "funny cat"},
+		{"x1y2z3/dance.mp4", "dance"},
+		{"abc/no-extension", "no extension"},
+		{"abc/.gif", ""},
+	}
+
+	for _, tt := range tests {
+		if got := mediaPathName(tt.path); got != tt.want {
+			t.Errorf("mediaPathName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidTenorId(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"12345678901234567", true},
+		{"AAAAC/funny-cat.gif", true},
+		{"https://tenor.com/view/x", false},
+		{"a/b/c", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidTenorId(tt.id); got != tt.valid {
+			t.Errorf("isValidTenorId(%q) = %v, want %v", tt.id, got, tt.valid)
+		}
+	}
+}
+
+func TestAppendKeywords(t *testing.T) {
+	many := make([]string, maxKeywords+5)
+	for i := range many {
+		many[i] = fmt.Sprintf("tag%d", i)
+	}
+
+	tests := []struct {
+		name     string
+		keywords []string
+		extra    []string
+		want     []string
+	}{
+		{"adds", []string{"cat"}, []string{"funny", "dance"}, []string{"cat", "funny", "dance"}},
+		{"skips duplicates", []string{"Cat"}, []string{"cat", "CAT", "dog", "dog"}, []string{"Cat", "dog"}},
+		{"trims", nil, []string{"  spaced  ", "   "}, []string{"spaced"}},
+		{"skips long", nil, []string{strings.Repeat("a", maxKeywordLength+1), "ok"}, []string{"ok"}},
+		{"stops at the limit", []string{"cat"}, many, append([]string{"cat"}, many[:maxKeywords-1]...)},
+		{"full list is kept", many[:maxKeywords], []string{"more"}, many[:maxKeywords]},
+	}
+
+	for _, tt := range tests {
+		got := appendKeywords(append([]string{}, tt.keywords...), tt.extra)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if len(got) > maxKeywords {
+			t.Errorf("%s: %d keywords, the limit is %d", tt.name, len(got), maxKeywords)
+		}
+	}
+}
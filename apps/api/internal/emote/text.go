@@ -0,0 +1,64 @@
+package emote
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxTextLength = 200
+
+// TextSource renders the id as a text-only sticker
+const TextSource = "text"
+
+var sourceText = register(&Source{
+	Name:     TextSource,
+	New:      newTextEmote,
+	Validate: isValidText,
+	// nothing is downloaded
+	NoCache: true,
+	Constraints: Constraints{
+		IDFormat: fmt.Sprintf("text of the sticker, up to %d characters", maxTextLength),
+	},
+})
+
+type textEmote struct {
+	text      string
+	keywords  []string
+	emojiList []string
+}
+
+func newTextEmote(input *EmoteInput, keywords []string) Emote {
+	return &textEmote{input.ID, keywords, input.EmojiList}
+}
+
+func isValidText(text string) bool {
+	return utf8.ValidString(text) &&
+		strings.TrimSpace(text) != "" &&
+		utf8.RuneCountInString(text) <= maxTextLength
+}
+
+func (e *textEmote) Download(ctx context.Context) (EmoteData, error) {
+	return EmoteData{
+		File: []byte(e.text),
+		Text: true,
+		Name: e.text,
+	}, nil
+}
+
+func (e *textEmote) ID() string {
+	return e.text
+}
+
+func (e *textEmote) Keywords() []string {
+	return e.keywords
+}
+
+func (e *textEmote) EmojiList() []string {
+	return e.emojiList
+}
+
+func (e *textEmote) String() string {
+	return fmt.Sprintf("text:%s", e.text)
+}
@@ -0,0 +1,254 @@
+package emote
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/gifscan"
+)
+
+const (
+	MediaPNG  = "image/png"
+	MediaJPEG = "image/jpeg"
+	MediaGIF  = "image/gif"
+	MediaWebP = "image/webp"
+	MediaAVIF = "image/avif"
+	MediaMP4  = "video/mp4"
+	// recognized only to be rejected with a clear error
+	MediaHEIC = "image/heic"
+)
+
+// per-file limits, videos and gifs are allowed to be bigger
+var uploadLimits = map[string]int{
+	MediaPNG:  5 * 1024 * 1024,
+	MediaJPEG: 5 * 1024 * 1024,
+	MediaWebP: 5 * 1024 * 1024,
+	MediaGIF:  15 * 1024 * 1024,
+	MediaAVIF: 15 * 1024 * 1024,
+	MediaMP4:  15 * 1024 * 1024,
+}
+
+// MaxUploadSize is the largest of the per-type limits
+const MaxUploadSize = 15 * 1024 * 1024
+
+// canvases above this are rejected before anything is decoded
+const maxImagePixels = 4096 * 4096
+
+// ErrorUploadTooLarge is returned by ValidateUpload for files that are
+// over the limit of their type, other errors are about the type itself
+var ErrorUploadTooLarge = errors.New("file is too large")
+
+// UploadSource takes its file from the request instead of downloading it
+const UploadSource = "upload"
+
+var sourceUpload = register(&Source{
+	Name:     UploadSource,
+	New:      newUploadEmote,
+	Validate: anyID,
+	NoCache:  true,
+	Constraints: Constraints{
+		IDFormat:    "name of the multipart file field",
+		MaxFileSize: MaxUploadSize,
+		Animated:    true,
+	},
+})
+
+type uploadEmote struct {
+	id        string
+	data      []byte
+	keywords  []string
+	emojiList []string
+}
+
+func newUploadEmote(input *EmoteInput, keywords []string) Emote {
+	return &uploadEmote{input.ID, input.Upload, keywords, input.EmojiList}
+}
+
+// SniffMedia detects the media type from the magic bytes,
+// an empty string is returned for anything that can't be uploaded
+func SniffMedia(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return MediaPNG
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return MediaJPEG
+	case bytes.HasPrefix(data, []byte("GIF87a")),
+		bytes.HasPrefix(data, []byte("GIF89a")):
+		return MediaGIF
+	case len(data) >= 12 &&
+		bytes.Equal(data[:4], []byte("RIFF")) &&
+		bytes.Equal(data[8:12], []byte("WEBP")):
+		return MediaWebP
+	case len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")):
+		return isoMediaType(isoBrands(data))
+	default:
+		return ""
+	}
+}
+
+// iso media brands by the type they stand for, avif and heic share the
+// container with mp4
+var isoBrandTypes = map[string]string{
+	"avif": MediaAVIF,
+	"avis": MediaAVIF,
+	"heic": MediaHEIC,
+	"heix": MediaHEIC,
+	"heim": MediaHEIC,
+	"heis": MediaHEIC,
+	"hevc": MediaHEIC,
+	"hevx": MediaHEIC,
+	"hevm": MediaHEIC,
+	"hevs": MediaHEIC,
+	"isom": MediaMP4,
+	"iso2": MediaMP4,
+	"iso3": MediaMP4,
+	"iso4": MediaMP4,
+	"iso5": MediaMP4,
+	"iso6": MediaMP4,
+	"mp41": MediaMP4,
+	"mp42": MediaMP4,
+	"mp4v": MediaMP4,
+	"mp4x": MediaMP4,
+	"avc1": MediaMP4,
+	"dash": MediaMP4,
+	"M4V ": MediaMP4,
+	"M4VH": MediaMP4,
+	"M4VP": MediaMP4,
+	"qt  ": MediaMP4,
+	"3gp4": MediaMP4,
+	"3gp5": MediaMP4,
+	"3gp6": MediaMP4,
+	"3g2a": MediaMP4,
+}
+
+// isoBrands is the major brand followed by the compatible ones
+func isoBrands(data []byte) []string {
+	size := int(binary.BigEndian.Uint32(data[:4]))
+	if size < 16 || size > len(data) {
+		size = min(len(data), 16)
+	}
+	brands := []string{string(data[8:12])}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(data[i:i+4]))
+	}
+	return brands
+}
+
+// isoMediaType goes by the major brand, generic ones like mif1 only say
+// it's an image, so the compatible brands decide then
+func isoMediaType(brands []string) string {
+	if mediaType, ok := isoBrandTypes[brands[0]]; ok {
+		return mediaType
+	}
+	for _, brand := range brands[1:] {
+		switch mediaType := isoBrandTypes[brand]; mediaType {
+		case MediaAVIF, MediaHEIC:
+			return mediaType
+		}
+	}
+	return ""
+}
+
+// IsAnimatedAVIF tells image sequences apart by their own brand
+func IsAnimatedAVIF(data []byte) bool {
+	return slices.Contains(isoBrands(data), "avis")
+}
+
+// ValidateUpload checks the file type and the size limit for that type
+func ValidateUpload(data []byte) error {
+	mediaType := SniffMedia(data)
+	if mediaType == "" {
+		return fmt.Errorf("unsupported file type")
+	}
+	if mediaType == MediaHEIC {
+		return fmt.Errorf("heic images are not supported, convert them to png or jpeg")
+	}
+	if limit := uploadLimits[mediaType]; len(data) > limit {
+		return fmt.Errorf(
+			"%w, %s files must not be larger than %d bytes",
+			ErrorUploadTooLarge, mediaType, limit,
+		)
+	}
+	return nil
+}
+
+func (e *uploadEmote) Download(ctx context.Context) (EmoteData, error) {
+	if e.data == nil {
+		return EmoteData{}, fmt.Errorf("no file uploaded for %s", e.id)
+	}
+	if err := ValidateUpload(e.data); err != nil {
+		return EmoteData{}, fmt.Errorf("upload %s: %w", e.id, err)
+	}
+
+	isAnimated, err := isAnimatedMedia(e.data)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("upload %s: %w", e.id, err)
+	}
+
+	return EmoteData{
+		File:     e.data,
+		Animated: isAnimated,
+	}, nil
+}
+
+func (e *uploadEmote) ID() string {
+	return e.id
+}
+
+func (e *uploadEmote) Keywords() []string {
+	return e.keywords
+}
+
+func (e *uploadEmote) EmojiList() []string {
+	return e.emojiList
+}
+
+func (e *uploadEmote) String() string {
+	return fmt.Sprintf("upload:%s", e.id)
+}
+
+// isAnimatedMedia expects data that already passed SniffMedia
+func isAnimatedMedia(data []byte) (bool, error) {
+	switch SniffMedia(data) {
+	case MediaMP4:
+		return true, nil
+	case MediaGIF:
+		frames, err := gifFrameCount(data)
+		if err != nil {
+			return false, err
+		}
+		return frames > 1, nil
+	case MediaWebP:
+		return isAnimatedWebP(data), nil
+	case MediaAVIF:
+		return IsAnimatedAVIF(data), nil
+	}
+	return false, nil
+}
+
+// gifFrameCount scans the blocks instead of decoding the frames, a small
+// file can declare a huge canvas or thousands of frames
+func gifFrameCount(data []byte) (int, error) {
+	info, err := gifscan.Scan(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read gif: %w", err)
+	}
+	if info.Width*info.Height > maxImagePixels {
+		return 0, fmt.Errorf("gif canvas %dx%d is too big", info.Width, info.Height)
+	}
+	return info.Frames, nil
+}
+
+// extended webp files start with a VP8X chunk that has the animation flag
+func isAnimatedWebP(data []byte) bool {
+	const animationFlag = 0x02
+	if len(data) < 21 || !bytes.Equal(data[12:16], []byte("VP8X")) {
+		return false
+	}
+	size := binary.LittleEndian.Uint32(data[16:20])
+	return size >= 1 && data[20]&animationFlag != 0
+}
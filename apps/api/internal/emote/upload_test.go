@@ -0,0 +1,111 @@
+package emote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// ftyp builds an iso media header with the major and compatible brands
+func ftyp(major string, compatible ...string) []byte {
+	brands := major + "\x00\x00\x00\x00" + strings.Join(compatible, "")
+	box := make([]byte, 8, 8+len(brands))
+	binary.BigEndian.PutUint32(box, uint32(8+len(brands)))
+	copy(box[4:], "ftyp")
+	return append(box, brands...)
+}
+
+func TestSniffMedia(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), MediaPNG},
+		{"jpeg", []byte("\xff\xd8\xff\xe0"), MediaJPEG},
+		{"gif87", []byte("GIF87a"), MediaGIF},
+		{"gif89", []byte("GIF89a"), MediaGIF},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), MediaWebP},
+		{"riff without webp", []byte("RIFF\x00\x00\x00\x00WAVE"), ""},
+		{"avif", ftyp("avif", "mif1", "miaf"), MediaAVIF},
+		{"avif sequence", ftyp("avis", "msf1", "avif"), MediaAVIF},
+		{"avif behind mif1", ftyp("mif1", "miaf", "avif"), MediaAVIF},
+		{"heic", ftyp("heic", "mif1", "heic"), MediaHEIC},
+		{"heic behind mif1", ftyp("mif1", "heic"), MediaHEIC},
+		{"isom", ftyp("isom", "iso2", "avc1", "mp41"), MediaMP4},
+		{"mp42", ftyp("mp42", "isom"), MediaMP4},
+		{"m4v", ftyp("M4V ", "M4V ", "mp42"), MediaMP4},
+		{"mp4x", ftyp("mp4x"), MediaMP4},
+		{"quicktime", ftyp("qt  "), MediaMP4},
+		{"bare mif1", ftyp("mif1", "miaf"), ""},
+		{"unknown brand", ftyp("crx "), ""},
+		{"short ftyp", []byte("\x00\x00\x00\x08ftyp"), ""},
+		{"text", []byte("<html></html>"), ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := SniffMedia(tt.data); got != tt.want {
+			t.Errorf("%s: SniffMedia = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateUploadRejectsHEIC(t *testing.T) {
+	if err := ValidateUpload(ftyp("heic", "mif1")); err == nil ||
+		!strings.Contains(err.Error(), "heic") {
+		t.Errorf("ValidateUpload(heic) = %v, want a heic error", err)
+	}
+}
+
+func TestValidateUploadTooLarge(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	tests := []struct {
+		name     string
+		data     []byte
+		tooLarge bool
+	}{
+		{"png over the limit", append(png, bytes.Repeat([]byte{0}, 5*1024*1024)...), true},
+		{"heic", ftyp("heic", "mif1"), false},
+		{"unsupported", []byte("<html></html>"), false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateUpload(tt.data)
+		if err == nil {
+			t.Errorf("%s: ValidateUpload = nil, want an error", tt.name)
+			continue
+		}
+		if got := errors.Is(err, ErrorUploadTooLarge); got != tt.tooLarge {
+			t.Errorf("%s: too large = %v, want %v", tt.name, got, tt.tooLarge)
+		}
+	}
+}
+
+func TestIsAnimatedMedia(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"mp4", ftyp("isom"), true},
+		{"avif", ftyp("avif", "mif1"), false},
+		{"avif sequence", ftyp("avis", "msf1"), true},
+		{"sequence behind avif", ftyp("avif", "avis"), true},
+		{"static webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x00"), false},
+		{"animated webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x02"), true},
+	}
+
+	for _, tt := range tests {
+		got, err := isAnimatedMedia(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: isAnimatedMedia = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package emote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
+)
+
+const (
+	maxURLDownloadSize = 10 * 1024 * 1024 // 10 MB
+	maxURLRedirects    = 5
+)
+
+var sourceURL = register(&Source{
+	Name:     "url",
+	New:      newURLEmote,
+	Validate: isValidEmoteURL,
+	Client: &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			// a proxy would make the dialer check the wrong address
+			Proxy: nil,
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+				Control: blockInternalAddress,
+			}).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     30 * time.Second,
+		},
+		CheckRedirect: checkURLRedirect,
+	},
+	// arbitrary hosts are not hammered
+	Retries: 1,
+	Constraints: Constraints{
+		IDFormat:    "https url of a png, jpeg, gif or webp",
+		MaxFileSize: maxURLDownloadSize,
+		Animated:    true,
+	},
+})
+
+var (
+	// ranges net.IP has no method for
+	blockedNetworks = parseCIDRs(
+		"0.0.0.0/8",     // this network
+		"100.64.0.0/10", // shared address space
+		"198.18.0.0/15", // benchmarking
+		"64:ff9b::/96",  // nat64, reaches ipv4 through a gateway
+		"2002::/16",     // 6to4, embeds an ipv4 address
+	)
+	errBlockedAddress = errors.New("address is not allowed")
+)
+
+// media types that are accepted from arbitrary urls
+var urlMediaTypes = map[string]bool{
+	MediaPNG:  true,
+	MediaJPEG: true,
+	MediaGIF:  true,
+	MediaWebP: true,
+	MediaAVIF: true,
+}
+
+type urlEmote struct {
+	url       string
+	keywords  []string
+	emojiList []string
+}
+
+func newURLEmote(input *EmoteInput, keywords []string) Emote {
+	return &urlEmote{input.ID, keywords, input.EmojiList}
+}
+
+func isValidEmoteURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return false
+	}
+	if u.User != nil {
+		return false
+	}
+	// literal ips get checked again by the dialer, this just fails early
+	if ip := net.ParseIP(u.Hostname()); ip != nil && isBlockedIP(ip) {
+		return false
+	}
+	return true
+}
+
+// blockInternalAddress runs for every connection after DNS resolution,
+// so it also covers redirects and hosts that resolve to internal ips
+func blockInternalAddress(
+	network string,
+	address string,
+	_ syscall.RawConn,
+) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isBlockedIP(ip) {
+		return fmt.Errorf("%w: %s", errBlockedAddress, host)
+	}
+	return nil
+}
+
+func isBlockedIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		slices.ContainsFunc(blockedNetworks, func(n *net.IPNet) bool {
+			return n.Contains(ip)
+		})
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+func checkURLRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxURLRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxURLRedirects)
+	}
+	if req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to non-https url %s", req.URL)
+	}
+	return nil
+}
+
+func (e *urlEmote) Download(ctx context.Context) (EmoteData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url, nil)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed creating request: %w", err)
+	}
+	retryParams := &retrier.RetryParams{
+		Request: req,
+		Client:  sourceURL.Client,
+		Retries: sourceURL.Retries,
+	}
+	resp, err := retrier.RequestWithCallback(ctx, retryParams, urlRespCallback)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to download %s: %w", e.url, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxURLDownloadSize+1))
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("failed to read %s: %w", e.url, err)
+	}
+	if len(data) > maxURLDownloadSize {
+		return EmoteData{}, fmt.Errorf(
+			"%s is larger than %d bytes", e.url, maxURLDownloadSize,
+		)
+	}
+
+	// the header can lie, check the actual content too
+	if !urlMediaTypes[SniffMedia(data)] {
+		return EmoteData{}, fmt.Errorf("%s is not a supported image", e.url)
+	}
+	isAnimated, err := isAnimatedMedia(data)
+	if err != nil {
+		return EmoteData{}, fmt.Errorf("%s: %w", e.url, err)
+	}
+
+	return EmoteData{
+		File:     data,
+		Animated: isAnimated,
+		Name:     urlFileName(resp.Request.URL),
+	}, nil
+}
+
+func (e *urlEmote) ID() string {
+	return e.url
+}
+
+func (e *urlEmote) Keywords() []string {
+	return e.keywords
+}
+
+func (e *urlEmote) EmojiList() []string {
+	return e.emojiList
+}
+
+func (e *urlEmote) String() string {
+	return fmt.Sprintf("url:%s", e.url)
+}
+
+// urlFileName is the last path segment without the extension
+func urlFileName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+func urlRespCallback(resp *http.Response) (bool, error) {
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return true, fmt.Errorf(
+			"request returned unexpected status code %d", resp.StatusCode,
+		)
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("url returned status code %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	// some CDNs don't bother with the type or send none at all,
+	// the content is sniffed anyway
+	if mediaType != "" && !urlMediaTypes[mediaType] &&
+		mediaType != "application/octet-stream" {
+		return false, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+	if resp.ContentLength > maxURLDownloadSize {
+		return false, fmt.Errorf(
+			"content length %d exceeds %d bytes",
+			resp.ContentLength,
+			maxURLDownloadSize,
+		)
+	}
+
+	return false, nil
+}
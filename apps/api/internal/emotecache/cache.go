@@ -0,0 +1,261 @@
+package emotecache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/resize"
+)
+
+const fileSuffix = ".emote"
+
+// Cache keeps downloaded and fitted emotes on disk, the least recently
+// used files are removed once the size limit is hit.
+// A nil *Cache is valid and caches nothing.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // front is the most recently used
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	name string
+	size int64
+}
+
+// New opens the cache directory and picks up the files that are already
+// there, oldest modification time is evicted first
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache dir: %w", err)
+	}
+
+	type existing struct {
+		entry
+		modTime time.Time
+	}
+	var found []existing
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileSuffix) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, existing{
+			entry:   entry{name: file.Name(), size: info.Size()},
+			modTime: info.ModTime(),
+		})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].modTime.After(found[j].modTime)
+	})
+
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	for _, f := range found {
+		c.entries[f.name] = c.order.PushBack(&entry{f.name, f.size})
+		c.size += f.size
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+
+	log.Printf("emote cache in %s: %d files, %d bytes", dir, len(found), c.size)
+	return c, nil
+}
+
+// Download returns the raw emote from the cache or downloads it
+func (c *Cache) Download(
+	ctx context.Context,
+	input *emote.EmoteInput,
+	e emote.Emote,
+) (emote.EmoteData, error) {
+	if !input.Cacheable() {
+		return e.Download(ctx)
+	}
+
+	key := "raw:" + e.String()
+	if data, ok := c.load(key); ok {
+		return data, nil
+	}
+
+	data, err := e.Download(ctx)
+	if err != nil {
+		return emote.EmoteData{}, err
+	}
+	c.store(key, &data)
+	return data, nil
+}
+
+// FitEmote is resize.FitEmote with the result cached by the raw file
+// contents and the encoder settings
+func (c *Cache) FitEmote(
+	ctx context.Context,
+	data *emote.EmoteData,
+	opts emote.Options,
+	profile resize.Profile,
+) error {
+	if c == nil {
+		return resize.FitEmote(ctx, data, opts, profile)
+	}
+
+	sum := sha256.Sum256(data.File)
+	key := "fit:" + resize.SettingsKey(opts, profile) + ":" +
+		hex.EncodeToString(sum[:])
+	if cached, ok := c.load(key); ok {
+		*data = cached
+		return nil
+	}
+
+	if err := resize.FitEmote(ctx, data, opts, profile); err != nil {
+		return err
+	}
+	c.store(key, data)
+	return nil
+}
+
+func (c *Cache) load(key string) (emote.EmoteData, bool) {
+	if c == nil {
+		return emote.EmoteData{}, false
+	}
+
+	name := fileName(key)
+	c.mu.Lock()
+	elem, ok := c.entries[name]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return emote.EmoteData{}, false
+	}
+
+	path := filepath.Join(c.dir, name)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		c.remove(name)
+		return emote.EmoteData{}, false
+	}
+
+	var data emote.EmoteData
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&data); err != nil {
+		log.Printf("warn: dropping broken cache file %s: %v", name, err)
+		c.remove(name)
+		return emote.EmoteData{}, false
+	}
+
+	// keeps the order across restarts
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+// store is best effort, a failed write only means a cache miss later
+func (c *Cache) store(key string, data *emote.EmoteData) {
+	if c == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		log.Printf("warn: failed to encode cache entry: %v", err)
+		return
+	}
+	size := int64(buf.Len())
+	if size > c.maxSize {
+		return
+	}
+
+	name := fileName(key)
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		log.Printf("warn: failed to write cache entry: %v", err)
+		return
+	}
+	_, writeErr := tmp.Write(buf.Bytes())
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		log.Printf("warn: failed to write cache entry: %v %v", writeErr, closeErr)
+		return
+	}
+	// rename is atomic, readers never see a half written file
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("warn: failed to write cache entry: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[name]; ok {
+		c.size -= elem.Value.(*entry).size
+		c.order.Remove(elem)
+	}
+	c.entries[name] = c.order.PushFront(&entry{name, size})
+	c.size += size
+	c.evict()
+}
+
+func (c *Cache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[name]; ok {
+		c.size -= elem.Value.(*entry).size
+		c.order.Remove(elem)
+		delete(c.entries, name)
+	}
+	os.Remove(filepath.Join(c.dir, name))
+}
+
+// evict expects c.mu to be held
+func (c *Cache) evict() {
+	for c.size > c.maxSize {
+		elem := c.order.Back()
+		if elem == nil {
+			return
+		}
+		e := elem.Value.(*entry)
+		c.order.Remove(elem)
+		delete(c.entries, e.name)
+		c.size -= e.size
+		if err := os.Remove(filepath.Join(c.dir, e.name)); err != nil {
+			log.Printf("warn: failed to evict %s: %v", e.name, err)
+		}
+	}
+}
+
+// fileName hashes the key, so ids and urls never end up in paths
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + fileSuffix
+}
@@ -0,0 +1,203 @@
+package emotecache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+// entrySize is what store writes for data, the limits in the tests are
+// counted in these
+func entrySize(t *testing.T, data emote.EmoteData) int64 {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&data); err != nil {
+		t.Fatal(err)
+	}
+	return int64(buf.Len())
+}
+
+func payload(b byte) emote.EmoteData {
+	return emote.EmoteData{File: bytes.Repeat([]byte{b}, 100)}
+}
+
+func TestEviction(t *testing.T) {
+	size := entrySize(t, payload('a'))
+
+	tests := []struct {
+		name string
+		// keys are stored in order, a key starting with "load " is loaded
+		steps []string
+		kept  []string
+		gone  []string
+	}{
+		{
+			name:  "fits",
+			steps: []string{"a", "b", "c"},
+			kept:  []string{"a", "b", "c"},
+		},
+		{
+			name:  "oldest goes first",
+			steps: []string{"a", "b", "c", "d"},
+			kept:  []string{"b", "c", "d"},
+			gone:  []string{"a"},
+		},
+		{
+			name:  "loading keeps an entry",
+			steps: []string{"a", "b", "c", "load a", "d"},
+			kept:  []string{"a", "c", "d"},
+			gone:  []string{"b"},
+		},
+		{
+			name:  "storing again keeps an entry",
+			steps: []string{"a", "b", "c", "a", "d", "e"},
+			kept:  []string{"a", "d", "e"},
+			gone:  []string{"b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		c, err := New(t.TempDir(), 3*size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, step := range tt.steps {
+			if key, ok := strings.CutPrefix(step, "load "); ok {
+				c.load(key)
+				continue
+			}
+			data := payload(step[0])
+			c.store(step, &data)
+		}
+
+		if c.size > c.maxSize {
+			t.Errorf("%s: size %d is over the limit %d", tt.name, c.size, c.maxSize)
+		}
+		for _, key := range tt.kept {
+			data, ok := c.load(key)
+			if !ok || data.File[0] != key[0] {
+				t.Errorf("%s: %s was evicted", tt.name, key)
+			}
+		}
+		for _, key := range tt.gone {
+			if _, ok := c.load(key); ok {
+				t.Errorf("%s: %s was kept", tt.name, key)
+			}
+			if _, err := os.Stat(filepath.Join(c.dir, fileName(key))); !os.IsNotExist(err) {
+				t.Errorf("%s: file of %s is still there", tt.name, key)
+			}
+		}
+	}
+}
+
+func TestTooBigIsNotStored(t *testing.T) {
+	c, err := New(t.TempDir(), entrySize(t, payload('a'))-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := payload('a')
+	c.store("a", &data)
+	if _, ok := c.load("a"); ok {
+		t.Error("entry over the limit was stored")
+	}
+}
+
+func TestNewPicksUpFiles(t *testing.T) {
+	dir := t.TempDir()
+	size := entrySize(t, payload('a'))
+	c, err := New(dir, 3*size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"a", "b", "c"} {
+		data := payload(key[0])
+		c.store(key, &data)
+		// the order after a restart comes from the modification times
+		at := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(filepath.Join(dir, fileName(key)), at, at)
+	}
+	os.WriteFile(filepath.Join(dir, "unrelated.txt"), []byte("x"), 0644)
+
+	reopened, err := New(dir, 2*size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.load("a"); ok {
+		t.Error("oldest file was kept over the limit")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := reopened.load(key); !ok {
+			t.Errorf("%s was not picked up", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unrelated.txt")); err != nil {
+		t.Error("unrelated file was removed")
+	}
+}
+
+func TestBrokenFileIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, fileName("a")), []byte("garbage"), 0644)
+	c, err := New(dir, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.load("a"); ok {
+		t.Fatal("broken file was loaded")
+	}
+	if c.size != 0 || len(c.entries) != 0 {
+		t.Errorf("broken file is still counted: %d bytes, %d entries", c.size, len(c.entries))
+	}
+}
+
+// countingEmote counts downloads, its file is the number of the download
+type countingEmote struct {
+	emote.Emote
+	id        string
+	downloads int
+}
+
+func (e *countingEmote) Download(ctx context.Context) (emote.EmoteData, error) {
+	e.downloads++
+	return emote.EmoteData{File: []byte{byte(e.downloads)}}, nil
+}
+
+func (e *countingEmote) String() string {
+	return e.id
+}
+
+func TestDownload(t *testing.T) {
+	tests := []struct {
+		input     emote.EmoteInput
+		downloads int
+	}{
+		{emote.EmoteInput{Source: "7tv", ID: "01F6MQ33FG000FFJ97ZB8MWV52"}, 1},
+		{emote.EmoteInput{Source: "telegram", ID: "CAACAgIAAxkBAAEBQ2Nk"}, 1},
+		// positions in a set change when the set is edited
+		{emote.EmoteInput{Source: "telegram", ID: "Animals:3"}, 2},
+		{emote.EmoteInput{Source: emote.UploadSource, ID: "file0"}, 2},
+	}
+
+	for _, tt := range tests {
+		c, err := New(t.TempDir(), 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := &countingEmote{id: tt.input.Source + ":" + tt.input.ID}
+		for range 2 {
+			if _, err := c.Download(context.Background(), &tt.input, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if e.downloads != tt.downloads {
+			t.Errorf("%s: %d downloads, want %d", e.id, e.downloads, tt.downloads)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package gifscan reads the structure of a gif without decoding any
+// pixels, so the size of untrusted files can be checked before
+// image/gif allocates frames for them
+package gifscan
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	extensionIntroducer = 0x21
+	imageSeparator      = 0x2c
+	trailer             = 0x3b
+	graphicControlLabel = 0xf9
+	colorTableFlag      = 0x80
+)
+
+var errTruncated = errors.New("gif is truncated")
+
+// Info describes a gif as image/gif.DecodeAll would see it
+type Info struct {
+	// logical screen
+	Width  int
+	Height int
+	Frames int
+	// per frame in 100ths of a second, 0 for frames without a
+	// graphic control extension
+	Delays []int
+	// sum of the frame areas, DecodeAll allocates a byte for each
+	FramePixels int64
+}
+
+// Scan walks the blocks of the gif, image data is skipped
+func Scan(data []byte) (Info, error) {
+	if len(data) < 13 ||
+		(string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return Info{}, fmt.Errorf("not a gif")
+	}
+
+	info := Info{
+		Width:  int(binary.LittleEndian.Uint16(data[6:8])),
+		Height: int(binary.LittleEndian.Uint16(data[8:10])),
+	}
+	pos := 13 + colorTableSize(data[10])
+
+	delay := 0
+	for {
+		if pos >= len(data) {
+			return info, errTruncated
+		}
+		block := data[pos]
+		pos++
+
+		switch block {
+		case trailer:
+			return info, nil
+
+		case extensionIntroducer:
+			if pos >= len(data) {
+				return info, errTruncated
+			}
+			label := data[pos]
+			pos++
+			if label == graphicControlLabel && pos+4 < len(data) && data[pos] >= 4 {
+				delay = int(binary.LittleEndian.Uint16(data[pos+2 : pos+4]))
+			}
+			var err error
+			if pos, err = skipSubBlocks(data, pos); err != nil {
+				return info, err
+			}
+
+		case imageSeparator:
+			if pos+9 > len(data) {
+				return info, errTruncated
+			}
+			width := int64(binary.LittleEndian.Uint16(data[pos+4 : pos+6]))
+			height := int64(binary.LittleEndian.Uint16(data[pos+6 : pos+8]))
+			pos += 9 + colorTableSize(data[pos+8])
+			// lzw minimum code size
+			pos++
+			var err error
+			if pos, err = skipSubBlocks(data, pos); err != nil {
+				return info, err
+			}
+
+			info.Frames++
+			info.Delays = append(info.Delays, delay)
+			info.FramePixels += width * height
+			delay = 0
+
+		default:
+			return info, fmt.Errorf("unknown gif block 0x%02x", block)
+		}
+	}
+}
+
+func colorTableSize(flags byte) int {
+	if flags&colorTableFlag == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
+
+// skipSubBlocks returns the position after the block terminator
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return pos, errTruncated
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+}
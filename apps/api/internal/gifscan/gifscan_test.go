@@ -0,0 +1,124 @@
+package gifscan
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"slices"
+	"testing"
+)
+
+func encode(t *testing.T, g *gif.GIF) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func frame(rect image.Rectangle) *image.Paletted {
+	img := image.NewPaletted(rect, palette.Plan9)
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 200)
+	}
+	return img
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name string
+		gif  *gif.GIF
+	}{
+		{
+			name: "single frame",
+			gif: &gif.GIF{
+				Image: []*image.Paletted{frame(image.Rect(0, 0, 40, 30))},
+				Delay: []int{0},
+			},
+		},
+		{
+			name: "frames with delays",
+			gif: &gif.GIF{
+				Image: []*image.Paletted{
+					frame(image.Rect(0, 0, 64, 64)),
+					frame(image.Rect(10, 10, 30, 40)),
+					frame(image.Rect(0, 0, 64, 64)),
+				},
+				Delay: []int{2, 10, 300},
+			},
+		},
+		{
+			name: "local palette",
+			gif: &gif.GIF{
+				Image: []*image.Paletted{
+					image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{
+						color.Black, color.White, color.Transparent,
+					}),
+					frame(image.Rect(0, 0, 8, 8)),
+				},
+				Delay: []int{5, 5},
+				Config: image.Config{
+					ColorModel: color.Palette(palette.WebSafe), Width: 8, Height: 8,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encode(t, tt.gif)
+			want, err := gif.DecodeAll(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := Scan(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Width != want.Config.Width || info.Height != want.Config.Height {
+				t.Errorf("screen %dx%d, want %dx%d",
+					info.Width, info.Height, want.Config.Width, want.Config.Height)
+			}
+			if info.Frames != len(want.Image) {
+				t.Errorf("frames %d, want %d", info.Frames, len(want.Image))
+			}
+			if !slices.Equal(info.Delays, want.Delay) {
+				t.Errorf("delays %v, want %v", info.Delays, want.Delay)
+			}
+			var pixels int64
+			for _, img := range want.Image {
+				pixels += int64(len(img.Pix))
+			}
+			if info.FramePixels != pixels {
+				t.Errorf("frame pixels %d, want %d", info.FramePixels, pixels)
+			}
+		})
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	data := encode(t, &gif.GIF{
+		Image: []*image.Paletted{frame(image.Rect(0, 0, 16, 16))},
+		Delay: []int{0},
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"png", []byte("\x89PNG\r\n\x1a\n0000000000000")},
+		{"header only", data[:13]},
+		{"truncated image data", data[:len(data)-4]},
+		{"unknown block", append(append([]byte{}, data[:len(data)-1]...), 0x99)},
+	}
+	for _, tt := range tests {
+		if _, err := Scan(tt.data); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
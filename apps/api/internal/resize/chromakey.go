@@ -0,0 +1,161 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// percent, enough for jpeg and gif dithering noise
+	defaultKeyTolerance = 12
+	// colors just past the tolerance fade out over this distance
+	keyBlend = 0.08
+	// the most common border color has to cover this share of the
+	// border to count as the background
+	minBorderShare = 0.6
+	// alpha of border pixels that count as opaque
+	opaqueAlpha = 250
+)
+
+// keySimilarity is the tolerance as the ffmpeg colorkey similarity
+func keySimilarity(key *emote.BackgroundKey) float64 {
+	tolerance := key.Tolerance
+	if tolerance == 0 {
+		tolerance = defaultKeyTolerance
+	}
+	return float64(tolerance) / 100
+}
+
+// keyColor is the explicit key or the dominant border color, false
+// means there's no solid background to remove
+func keyColor(key *emote.BackgroundKey, img image.Image) (color.NRGBA, bool) {
+	if c, ok := key.Key(); ok {
+		return c, true
+	}
+	return borderColor(img)
+}
+
+// borderColor finds the most common opaque color around the edges,
+// similar colors are grouped so noise doesn't split the background
+func borderColor(img image.Image) (color.NRGBA, bool) {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := make(map[[3]uint8]*bucket)
+	total := 0
+	var best *bucket
+
+	add := func(x, y int) {
+		total++
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		if c.A < opaqueAlpha {
+			return
+		}
+		key := [3]uint8{c.R >> 3, c.G >> 3, c.B >> 3}
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{}
+			buckets[key] = b
+		}
+		b.count++
+		b.r += int(c.R)
+		b.g += int(c.G)
+		b.b += int(c.B)
+		if best == nil || b.count > best.count {
+			best = b
+		}
+	}
+
+	bounds := img.Bounds()
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		add(x, bounds.Min.Y)
+		add(x, bounds.Max.Y-1)
+	}
+	for y := bounds.Min.Y + 1; y < bounds.Max.Y-1; y++ {
+		add(bounds.Min.X, y)
+		add(bounds.Max.X-1, y)
+	}
+
+	if best == nil || float64(best.count) < minBorderShare*float64(total) {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{
+		uint8(best.r / best.count),
+		uint8(best.g / best.count),
+		uint8(best.b / best.count),
+		255,
+	}, true
+}
+
+// removeBackground makes the key color transparent the same way the
+// ffmpeg colorkey filter does, the existing alpha is kept
+func removeBackground(img image.Image, key *emote.BackgroundKey) image.Image {
+	c, ok := keyColor(key, img)
+	if !ok {
+		return img
+	}
+	similarity := keySimilarity(key)
+	return imaging.AdjustFunc(img, func(p color.NRGBA) color.NRGBA {
+		dr := float64(p.R) - float64(c.R)
+		dg := float64(p.G) - float64(c.G)
+		db := float64(p.B) - float64(c.B)
+		diff := math.Sqrt((dr*dr + dg*dg + db*db) / (3 * 255 * 255))
+		keep := math.Max(0, math.Min(1, (diff-similarity)/keyBlend))
+		p.A = uint8(math.Round(float64(p.A) * keep))
+		return p
+	})
+}
+
+// backgroundFilter is the colorkey filter for an animated emote, the
+// background is detected on the first frame. It's empty when there's
+// nothing to remove
+func backgroundFilter(
+	ctx context.Context,
+	key *emote.BackgroundKey,
+	source *ffmpegInput,
+) (string, error) {
+	c, ok := key.Key()
+	if !ok {
+		frame, err := firstFrame(ctx, source)
+		if err != nil {
+			return "", fmt.Errorf("failed to detect the background: %w", err)
+		}
+		if c, ok = borderColor(frame); !ok {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf(
+		"format=rgba,colorkey=0x%02x%02x%02x:%.2f:%.2f",
+		c.R, c.G, c.B, keySimilarity(key), keyBlend,
+	), nil
+}
+
+// firstFrame decodes the first frame of the source
+func firstFrame(ctx context.Context, source *ffmpegInput) (image.Image, error) {
+	if source.firstFrame != nil {
+		return source.firstFrame, nil
+	}
+
+	args := append([]string{}, source.args...)
+	args = append(args,
+		"-frames:v", "1",
+		"-c:v", "png",
+		"-pix_fmt", "rgba",
+		"-f", "image2pipe",
+		"pipe:1",
+	)
+	out, err := ffmpeg(args...).output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(out))
+}
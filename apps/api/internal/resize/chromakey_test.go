@@ -0,0 +1,111 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+// framed is a size x size image of the border color with a red middle
+func framed(size int, border func(x, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetNRGBA(x, y, border(x, y))
+		}
+	}
+	middle := image.Rect(size/4, size/4, size*3/4, size*3/4)
+	draw.Draw(img, middle, image.NewUniform(color.NRGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestBorderColor(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	tests := []struct {
+		name   string
+		border func(x, y int) color.NRGBA
+		want   color.NRGBA
+		ok     bool
+	}{
+		{"solid", func(x, y int) color.NRGBA { return white }, white, true},
+		{
+			// jpeg noise lands in the same bucket and is averaged
+			"noisy",
+			func(x, y int) color.NRGBA {
+				if (x+y)%2 == 0 {
+					return color.NRGBA{250, 250, 250, 255}
+				}
+				return color.NRGBA{254, 254, 254, 255}
+			},
+			color.NRGBA{252, 252, 252, 255},
+			true,
+		},
+		{"transparent", func(x, y int) color.NRGBA { return color.NRGBA{} }, color.NRGBA{}, false},
+		{
+			"split",
+			func(x, y int) color.NRGBA {
+				if x < 10 {
+					return white
+				}
+				return color.NRGBA{0, 0, 255, 255}
+			},
+			color.NRGBA{},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := borderColor(framed(20, tt.border))
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: borderColor = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestKeySimilarity(t *testing.T) {
+	tests := []struct {
+		tolerance int
+		want      float64
+	}{
+		{0, 0.12},
+		{30, 0.3},
+		{100, 1},
+	}
+
+	for _, tt := range tests {
+		key := &emote.BackgroundKey{Tolerance: tt.tolerance}
+		if got := keySimilarity(key); got != tt.want {
+			t.Errorf("keySimilarity(%d) = %v, want %v", tt.tolerance, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveBackground(t *testing.T) {
+	white := func(x, y int) color.NRGBA { return color.NRGBA{255, 255, 255, 255} }
+	tests := []struct {
+		name   string
+		key    emote.BackgroundKey
+		corner uint8
+	}{
+		{"detected", emote.BackgroundKey{}, 0},
+		{"explicit", emote.BackgroundKey{Color: "ffffff"}, 0},
+		{"other color", emote.BackgroundKey{Color: "00ff00"}, 255},
+	}
+
+	for _, tt := range tests {
+		img := removeBackground(framed(20, white), &tt.key)
+		nrgba, ok := img.(*image.NRGBA)
+		if !ok {
+			t.Fatalf("%s: removeBackground returned %T", tt.name, img)
+		}
+		if got := nrgba.NRGBAAt(0, 0).A; got != tt.corner {
+			t.Errorf("%s: corner alpha = %d, want %d", tt.name, got, tt.corner)
+		}
+		if got := nrgba.NRGBAAt(10, 10).A; got != 255 {
+			t.Errorf("%s: middle alpha = %d, want 255", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+const (
+	// every effect loops a whole number of times in this
+	effectDuration = 2.0
+	// shares of the sticker size
+	shakeAmplitude = 0.04
+	bounceHeight   = 0.15
+	pulseDepth     = 0.12
+)
+
+// animateStatic turns a fitted png into a looping video sticker with the
+// effects applied in order, the frame size stays the same
+func animateStatic(ctx context.Context, pngData []byte, effects []string) ([]byte, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(pngData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read png size: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "effects")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	inputPath := filepath.Join(tmpDir, "input.png")
+	if err := os.WriteFile(inputPath, pngData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input file: %w", err)
+	}
+
+	source := &ffmpegInput{
+		args: []string{
+			"-loop", "1",
+			"-framerate", fmt.Sprintf("%d", maxFPS),
+			"-t", fmt.Sprintf("%.2f", effectDuration),
+			"-i", inputPath,
+		},
+		info: &videoInfo{
+			FPS:      maxFPS,
+			Duration: effectDuration,
+			Width:    cfg.Width,
+			Height:   cfg.Height,
+		},
+	}
+	filter := effectsFilter(effects, cfg.Width, cfg.Height)
+	timing := timeline{duration: effectDuration, fps: maxFPS}
+
+	frameSize := image.Pt(cfg.Width, cfg.Height)
+	output, report, err := encodeVideo(
+		ctx, tmpDir, source, filter, frameSize, timing,
+	)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("encoded effect sticker: %s", report)
+	return output, nil
+}
+
+// effectsFilter chains the effects, each one takes and returns a
+// width x height rgba stream
+func effectsFilter(effects []string, width, height int) string {
+	side := float64(min(width, height))
+	parts := []string{"format=rgba"}
+	for i, effect := range effects {
+		switch effect {
+		case emote.EffectShake:
+			amplitude := shakeAmplitude * side
+			// different frequencies on the axes, both loop in the duration
+			parts = append(parts, motionFilter(i, width, height,
+				scaleBy(1-2*shakeAmplitude),
+				fmt.Sprintf("(W-w)/2+%.1f*sin(2*PI*7*t)", amplitude),
+				fmt.Sprintf("(H-h)/2+%.1f*cos(2*PI*9*t)", amplitude),
+			))
+		case emote.EffectSpin:
+			// the rotated corners have to stay inside the frame
+			shrink := side / math.Hypot(float64(width), float64(height))
+			parts = append(parts, motionFilter(i, width, height,
+				scaleBy(shrink)+fmt.Sprintf(
+					",rotate=a='2*PI*t/%.1f':ow='hypot(iw,ih)':oh=ow:c=none",
+					effectDuration,
+				),
+				"(W-w)/2", "(H-h)/2",
+			))
+		case emote.EffectPulse:
+			scale := fmt.Sprintf("(1-%[1]g/2+%[1]g/2*sin(2*PI*t))", pulseDepth)
+			parts = append(parts, motionFilter(i, width, height,
+				fmt.Sprintf("scale=w='iw*%[1]s':h='ih*%[1]s':eval=frame", scale),
+				"(W-w)/2", "(H-h)/2",
+			))
+		case emote.EffectBounce:
+			parts = append(parts, motionFilter(i, width, height,
+				scaleBy(1-bounceHeight),
+				"(W-w)/2", "(H-h)*(1-abs(sin(PI*t)))",
+			))
+		case emote.EffectRainbow:
+			parts = append(parts, colorFilter(fmt.Sprintf("fx%d", i), fmt.Sprintf(
+				"hue=h='360*t/%.1f'", effectDuration,
+			)))
+		case emote.EffectDeepFry:
+			parts = append(parts, colorFilter(fmt.Sprintf("fx%d", i),
+				"eq=saturation=2.5:contrast=1.6,unsharp=5:5:2.0,noise=alls=16:allf=t",
+			))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func scaleBy(factor float64) string {
+	return fmt.Sprintf("scale=iw*%[1]f:ih*%[1]f", factor)
+}
+
+// motionFilter transforms the art and moves it around a transparent
+// canvas of the original size, x and y are overlay expressions
+func motionFilter(index, width, height int, transform, x, y string) string {
+	return fmt.Sprintf(
+		"%[1]s,format=rgba[art%[2]d];"+
+			"color=c=0x00000000:s=%[3]dx%[4]d:r=%[5]d:d=%.2[6]f,format=rgba[canvas%[2]d];"+
+			"[canvas%[2]d][art%[2]d]overlay=x='%[7]s':y='%[8]s':shortest=1:format=auto,format=rgba",
+		transform, index, width, height, maxFPS, effectDuration, x, y,
+	)
+}
+
+// colorFilter runs a color filter on the opaque part, the alpha is split
+// off since the color filters only take yuv. label keeps the pads of
+// several color filters apart
+func colorFilter(label, filter string) string {
+	return fmt.Sprintf(
+		"format=rgba,split[%[1]scolor][%[1]salpha];[%[1]salpha]alphaextract[%[1]smask];"+
+			"[%[1]scolor]format=yuv444p,%[2]s[%[1]stinted];"+
+			"[%[1]stinted][%[1]smask]alphamerge,format=rgba",
+		label, filter,
+	)
+}
@@ -0,0 +1,58 @@
+package resize
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+var filterLabel = regexp.MustCompile(`\[[a-z0-9]+\]`)
+
+func TestEffectsFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		effects  []string
+		contains []string
+	}{
+		{"none", nil, nil},
+		{"shake", []string{emote.EffectShake}, []string{
+			"scale=iw*0.920000:ih*0.920000",
+			"sin(2*PI*7*t)",
+			"s=512x256:r=30:d=2.00",
+		}},
+		{"spin", []string{emote.EffectSpin}, []string{
+			"scale=iw*0.447214:ih*0.447214",
+			"rotate=a='2*PI*t/2.0'",
+		}},
+		{"pulse", []string{emote.EffectPulse}, []string{"(1-0.12/2+0.12/2*sin(2*PI*t))"}},
+		{"bounce", []string{emote.EffectBounce}, []string{"(H-h)*(1-abs(sin(PI*t)))"}},
+		{"rainbow", []string{emote.EffectRainbow}, []string{"hue=h='360*t/2.0'", "alphamerge"}},
+		{"deep fry", []string{emote.EffectDeepFry}, []string{"saturation=2.5"}},
+		{"chained", []string{emote.EffectShake, emote.EffectSpin}, []string{"[art0]", "[art1]"}},
+		{"same effect twice", []string{emote.EffectRainbow, emote.EffectRainbow}, []string{"[fx0color]", "[fx1color]"}},
+	}
+
+	for _, tt := range tests {
+		filter := effectsFilter(tt.effects, 512, 256)
+		if !strings.HasPrefix(filter, "format=rgba") {
+			t.Errorf("%s: filter does not start with format=rgba: %s", tt.name, filter)
+		}
+		for _, part := range tt.contains {
+			if !strings.Contains(filter, part) {
+				t.Errorf("%s: filter is missing %q: %s", tt.name, part, filter)
+			}
+		}
+		// every label is an output once and an input once
+		counts := make(map[string]int)
+		for _, label := range filterLabel.FindAllString(filter, -1) {
+			counts[label]++
+		}
+		for label, count := range counts {
+			if count != 2 {
+				t.Errorf("%s: label %s is used %d times", tt.name, label, count)
+			}
+		}
+	}
+}
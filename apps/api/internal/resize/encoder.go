@@ -0,0 +1,209 @@
+package resize
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// quality ceiling, the bitrate cap does the rest
+	encoderCRF = 18
+	// a search step ends the search when the output is this full
+	targetFill     = 0.92
+	maxSearchSteps = 6
+	// the lowest bitrate tried is the budget divided by this
+	minBitrateDivisor = 8
+)
+
+// qualityLevel is what's left to give up when the bitrate alone
+// doesn't fit the size limit
+type qualityLevel struct {
+	fps int
+	// the frames are downscaled and scaled back, the output size has
+	// to stay as telegram expects it
+	scale float64
+}
+
+// fps goes first, blurry stickers look worse than choppy ones
+var qualityLevels = []qualityLevel{
+	{fps: maxFPS, scale: 1},
+	{fps: 24, scale: 1},
+	{fps: 15, scale: 1},
+	{fps: 15, scale: 0.75},
+	{fps: 15, scale: 0.5},
+}
+
+// encodeReport describes the settings of the final encode
+type encodeReport struct {
+	bitrate int // kbit/s
+	fps     float64
+	scale   float64
+	size    int
+	encodes int
+}
+
+func (r encodeReport) String() string {
+	return fmt.Sprintf(
+		"%d kbit/s, %.0f fps, %.2f scale, %d bytes after %d encodes",
+		r.bitrate, r.fps, r.scale, r.size, r.encodes,
+	)
+}
+
+// encodeVideo binary searches the highest two-pass bitrate that fits
+// maxVideoSize, lowering the fps and the resolution when even the
+// lowest bitrate is too big. size is what the filter outputs, lower
+// resolutions are scaled back up to it
+func encodeVideo(
+	ctx context.Context,
+	tmpDir string,
+	source *ffmpegInput,
+	filter string,
+	size image.Point,
+	timing timeline,
+) ([]byte, encodeReport, error) {
+	budget := int(float64(maxVideoSize*8) / timing.duration / 1000)
+	encodes := 0
+
+	for _, level := range qualityLevels {
+		fps := timing.fps
+		levelFilter := filter
+		if float64(level.fps) < fps {
+			fps = float64(level.fps)
+			levelFilter += fmt.Sprintf(",fps=%d", level.fps)
+		}
+		if level.scale < 1 {
+			levelFilter += fmt.Sprintf(
+				",scale=iw*%g:ih*%g:flags=area,scale=%d:%d:flags=bicubic",
+				level.scale, level.scale, size.X, size.Y,
+			)
+		}
+
+		enc := &twoPassEncoder{
+			dir:      tmpDir,
+			source:   source,
+			filter:   levelFilter,
+			duration: timing.duration,
+		}
+		if err := enc.firstPass(ctx, budget); err != nil {
+			return nil, encodeReport{}, fmt.Errorf("first pass failed: %w", err)
+		}
+
+		best, bitrate, err := enc.search(ctx, budget/minBitrateDivisor, budget)
+		encodes += enc.encodes
+		if err != nil {
+			return nil, encodeReport{}, err
+		}
+		if best != nil {
+			return best, encodeReport{
+				bitrate: bitrate,
+				fps:     fps,
+				scale:   level.scale,
+				size:    len(best),
+				encodes: encodes,
+			}, nil
+		}
+	}
+
+	return nil, encodeReport{}, fmt.Errorf(
+		"output exceeds %d bytes at the lowest quality", maxVideoSize,
+	)
+}
+
+type twoPassEncoder struct {
+	dir      string
+	source   *ffmpegInput
+	filter   string
+	duration float64
+	encodes  int
+}
+
+// search returns the biggest output that fits and its bitrate,
+// the output is nil when nothing fits
+func (e *twoPassEncoder) search(ctx context.Context, low, high int) ([]byte, int, error) {
+	var best []byte
+	bestBitrate := 0
+
+	// most emotes are simple enough to fit at the full budget
+	bitrate := high
+	for step := 0; step < maxSearchSteps && low <= high; step++ {
+		output, err := e.secondPass(ctx, bitrate)
+		if err != nil {
+			return nil, 0, fmt.Errorf("second pass at %dk failed: %w", bitrate, err)
+		}
+
+		if len(output) <= maxVideoSize {
+			best, bestBitrate = output, bitrate
+			if float64(len(output)) >= targetFill*maxVideoSize || bitrate == high {
+				break
+			}
+			low = bitrate + 1
+		} else {
+			high = bitrate - 1
+		}
+		bitrate = (low + high) / 2
+	}
+	return best, bestBitrate, nil
+}
+
+func (e *twoPassEncoder) passLog() string {
+	return filepath.Join(e.dir, "pass")
+}
+
+// firstPass collects the statistics, they barely depend on the bitrate
+// so one run is shared by the whole search
+func (e *twoPassEncoder) firstPass(ctx context.Context, bitrate int) error {
+	args := e.args(bitrate)
+	args = append(args,
+		"-pass", "1",
+		"-cpu-used", "4",
+		"-f", "null",
+		os.DevNull,
+	)
+	return ffmpeg(args...).run(ctx)
+}
+
+func (e *twoPassEncoder) secondPass(ctx context.Context, bitrate int) ([]byte, error) {
+	e.encodes++
+	outputPath := filepath.Join(e.dir, "output.webm")
+	args := e.args(bitrate)
+	args = append(args,
+		"-pass", "2",
+		"-cpu-used", "1",
+		"-auto-alt-ref", "1",
+		"-lag-in-frames", "16",
+		"-f", "webm",
+		outputPath,
+	)
+	if err := ffmpeg(args...).run(ctx); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(outputPath)
+}
+
+// args are shared by both passes
+func (e *twoPassEncoder) args(bitrate int) []string {
+	threads := fmt.Sprintf("%d", min(numCPUs, 4)) // cap at 4 threads
+
+	args := []string{"-y"}
+	args = append(args, e.source.args...)
+	args = append(args,
+		"-t", fmt.Sprintf("%.2f", e.duration),
+		"-vf", e.filter,
+		"-fps_mode", "vfr", // keeps variable frame delays
+		"-c:v", "libvpx-vp9",
+		"-pix_fmt", "yuva420p",
+		"-crf", fmt.Sprintf("%d", encoderCRF),
+		"-b:v", fmt.Sprintf("%dk", bitrate),
+		"-passlogfile", e.passLog(),
+		"-an", // No audio
+		"-threads", threads,
+		"-row-mt", "1",
+		"-tile-columns", "2",
+		"-quality", "good",
+		"-static-thresh", "0",
+	)
+	return args
+}
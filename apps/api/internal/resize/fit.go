@@ -0,0 +1,206 @@
+package resize
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+// cropRect clips the requested crop to the emote
+func cropRect(crop *emote.CropRect, width, height int) (image.Rectangle, error) {
+	rect := image.Rect(
+		crop.X, crop.Y, crop.X+crop.Width, crop.Y+crop.Height,
+	).Intersect(image.Rect(0, 0, width, height))
+	if rect.Empty() {
+		return rect, fmt.Errorf("crop is outside of the %dx%d emote", width, height)
+	}
+	return rect, nil
+}
+
+// sourceRect is the part of the emote that gets scaled, either the crop
+// or the visible content with the trim margin around it
+func sourceRect(
+	opts emote.Options,
+	width, height int,
+	content image.Rectangle,
+) (image.Rectangle, error) {
+	full := image.Rect(0, 0, width, height)
+	if opts.Fit == emote.FitCrop {
+		return cropRect(opts.Crop, width, height)
+	}
+	if !opts.Trims() || content.Empty() {
+		return full, nil
+	}
+	return content.Inset(-opts.TrimMargin).Intersect(full), nil
+}
+
+// padded reports if the output is padded to a square
+func padded(opts emote.Options, profile Profile) bool {
+	return profile.Square || opts.Fit == emote.FitPad
+}
+
+// fitImage applies the fit mode and scales to the profile size,
+// the outline is drawn in the space left around the art
+func fitImage(img image.Image, opts emote.Options, profile Profile) (*image.NRGBA, error) {
+	size := profile.Size
+	inner := size - 2*outlineMargin(opts.Outline, profile)
+
+	if opts.RemoveBackground != nil {
+		img = removeBackground(img, opts.RemoveBackground)
+	}
+	img, err := applyTransforms(img, opts.Transforms)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	var content image.Rectangle
+	if opts.Trims() {
+		content = contentBounds(img).Sub(bounds.Min)
+	}
+	rect, err := sourceRect(opts, bounds.Dx(), bounds.Dy(), content)
+	if err != nil {
+		return nil, err
+	}
+	if rect.Size() != bounds.Size() {
+		img = imaging.Crop(img, rect.Add(bounds.Min))
+	}
+
+	bounds = img.Bounds()
+	filter := resampleFilter(opts.Resample)
+	scale := pixelScale(opts, bounds.Dx(), bounds.Dy(), inner, func() bool {
+		return isPixelArt(img)
+	})
+	if scale > 0 && opts.Fit == emote.FitCover {
+		visible := pixelCoverRect(bounds.Dx(), bounds.Dy(), inner, scale)
+		if visible.Size() != bounds.Size() {
+			img = imaging.Crop(img, visible.Add(bounds.Min))
+			bounds = img.Bounds()
+		}
+	}
+	var resized *image.NRGBA
+	switch {
+	case scale > 0:
+		width, height := bounds.Dx()*scale, bounds.Dy()*scale
+		scaled := imaging.Resize(img, width, height, imaging.NearestNeighbor)
+		canvasWidth, canvasHeight := pixelCanvas(opts, width, height, inner)
+		resized = imaging.PasteCenter(
+			imaging.New(canvasWidth, canvasHeight, color.Transparent), scaled,
+		)
+	case opts.Fit == emote.FitCover:
+		resized = imaging.Fill(img, inner, inner, imaging.Center, filter)
+	case bounds.Dx() >= bounds.Dy():
+		resized = imaging.Resize(img, inner, 0, filter)
+	default:
+		resized = imaging.Resize(img, 0, inner, filter)
+	}
+
+	if opts.Outline.Visible() {
+		resized = applyOutline(resized, opts.Outline, profile)
+	}
+	if opts.Caption != nil {
+		resized, err = applyCaption(resized, opts.Caption, profile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !padded(opts, profile) {
+		return resized, nil
+	}
+	canvas := imaging.New(size, size, color.Transparent)
+	return imaging.PasteCenter(canvas, resized), nil
+}
+
+// videoFilter is the ffmpeg equivalent of fitImage, content is the
+// union of the visible pixels of all frames. detect reports if the
+// source is pixel art, the caption is rendered into tmpDir. The size
+// of the filtered frames is returned with the filter
+func videoFilter(
+	opts emote.Options,
+	profile Profile,
+	info *videoInfo,
+	content image.Rectangle,
+	detect func() bool,
+	tmpDir string,
+) (string, image.Point, error) {
+	size := profile.Size
+	inner := size - 2*outlineMargin(opts.Outline, profile)
+
+	rect, err := sourceRect(opts, info.Width, info.Height, content)
+	if err != nil {
+		return "", image.Point{}, err
+	}
+	scale := pixelScale(opts, rect.Dx(), rect.Dy(), inner, detect)
+	if scale > 0 && opts.Fit == emote.FitCover {
+		rect = pixelCoverRect(rect.Dx(), rect.Dy(), inner, scale).Add(rect.Min)
+	}
+	filter := ""
+	if rect.Dx() != info.Width || rect.Dy() != info.Height {
+		filter = fmt.Sprintf(
+			"crop=%d:%d:%d:%d,",
+			rect.Dx(), rect.Dy(), rect.Min.X, rect.Min.Y,
+		)
+	}
+
+	flags := scaleFlags(opts.Resample)
+	// size of the art for the caption, ffmpeg rounds the contain
+	// side the same way
+	artWidth, artHeight := inner, inner
+	switch {
+	case scale > 0:
+		width, height := rect.Dx()*scale, rect.Dy()*scale
+		canvasWidth, canvasHeight := pixelCanvas(opts, width, height, inner)
+		artWidth, artHeight = canvasWidth, canvasHeight
+		filter += fmt.Sprintf("scale=%d:%d:flags=neighbor,format=rgba,", width, height)
+		if opts.Fit == emote.FitCover {
+			filter += fmt.Sprintf("crop=%d:%d", canvasWidth, canvasHeight)
+		} else {
+			filter += fmt.Sprintf(
+				"pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=0x00000000",
+				canvasWidth, canvasHeight,
+			)
+		}
+	case opts.Fit == emote.FitCover:
+		filter += fmt.Sprintf(
+			"scale=%[1]d:%[1]d:force_original_aspect_ratio=increase:flags=%[2]s,crop=%[1]d:%[1]d",
+			inner, flags,
+		)
+	default:
+		filter += fmt.Sprintf(
+			"scale='if(gt(a,1),%[1]d,-1)':'if(gt(a,1),-1,%[1]d)':flags=%[2]s", inner, flags,
+		)
+		if rect.Dx() > rect.Dy() {
+			artHeight = max(1, int(math.Round(float64(rect.Dy()*inner)/float64(rect.Dx()))))
+		} else {
+			artWidth = max(1, int(math.Round(float64(rect.Dx()*inner)/float64(rect.Dy()))))
+		}
+	}
+
+	if opts.Outline.Visible() {
+		filter += "," + outlineFilter(opts.Outline, profile)
+		margin := outlineMargin(opts.Outline, profile)
+		artWidth += 2 * margin
+		artHeight += 2 * margin
+	}
+	if opts.Caption != nil {
+		path := filepath.Join(tmpDir, "caption.png")
+		err := writeCaption(path, opts.Caption, artWidth, artHeight, profile)
+		if err != nil {
+			return "", image.Point{}, err
+		}
+		filter += "," + captionFilter(opts.Caption, path)
+	}
+	if padded(opts, profile) {
+		filter += fmt.Sprintf(
+			",format=rgba,pad=%[1]d:%[1]d:(ow-iw)/2:(oh-ih)/2:color=0x00000000",
+			size,
+		)
+		return filter, image.Pt(size, size), nil
+	}
+	return filter, image.Pt(artWidth, artHeight), nil
+}
@@ -0,0 +1,96 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+func TestSourceRect(t *testing.T) {
+	content := image.Rect(20, 30, 60, 50)
+	tests := []struct {
+		name    string
+		opts    emote.Options
+		content image.Rectangle
+		want    image.Rectangle
+		ok      bool
+	}{
+		{"trimmed to content", emote.Options{}, content, content, true},
+		{"margin", emote.Options{TrimMargin: 5}, content, image.Rect(15, 25, 65, 55), true},
+		{"margin is clipped", emote.Options{TrimMargin: 40}, content, image.Rect(0, 0, 100, 80), true},
+		{"no trim", emote.Options{NoTrim: true}, content, image.Rect(0, 0, 100, 80), true},
+		{"nothing visible", emote.Options{}, image.Rectangle{}, image.Rect(0, 0, 100, 80), true},
+		{
+			"crop",
+			emote.Options{Fit: emote.FitCrop, Crop: &emote.CropRect{X: 10, Y: 10, Width: 20, Height: 30}},
+			content,
+			image.Rect(10, 10, 30, 40),
+			true,
+		},
+		{
+			"crop is clipped",
+			emote.Options{Fit: emote.FitCrop, Crop: &emote.CropRect{X: 90, Y: 70, Width: 50, Height: 50}},
+			content,
+			image.Rect(90, 70, 100, 80),
+			true,
+		},
+		{
+			"crop outside",
+			emote.Options{Fit: emote.FitCrop, Crop: &emote.CropRect{X: 100, Y: 0, Width: 10, Height: 10}},
+			content,
+			image.Rectangle{},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := sourceRect(tt.opts, 100, 80, tt.content)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: sourceRect error = %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("%s: sourceRect = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFitModes(t *testing.T) {
+	tests := []struct {
+		name string
+		opts emote.Options
+		want image.Point
+		// pad leaves the corners empty, the others are filled
+		corner uint8
+	}{
+		{"contain", emote.Options{}, image.Pt(512, 256), 255},
+		{"cover", emote.Options{Fit: emote.FitCover}, image.Pt(512, 512), 255},
+		{"pad", emote.Options{Fit: emote.FitPad}, image.Pt(512, 512), 0},
+		{
+			"crop",
+			emote.Options{Fit: emote.FitCrop, Crop: &emote.CropRect{Width: 50, Height: 100}},
+			image.Pt(256, 512),
+			255,
+		},
+	}
+
+	for _, tt := range tests {
+		// a single color would be taken for pixel art
+		tt.opts.Resample = emote.ResampleBilinear
+		img := imaging.New(200, 100, color.NRGBA{255, 0, 0, 255})
+		fitted, err := fitImage(img, tt.opts, StickerProfile)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := fitted.Bounds().Size(); got != tt.want {
+			t.Errorf("%s: fitImage size = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := fitted.NRGBAAt(0, 0).A; got != tt.corner {
+			t.Errorf("%s: corner alpha = %d, want %d", tt.name, got, tt.corner)
+		}
+	}
+}
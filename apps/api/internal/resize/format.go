@@ -0,0 +1,73 @@
+package resize
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+// input formats, the names double as temp file extensions so ffmpeg
+// and ffprobe pick the right demuxer
+const (
+	formatUnknown = ""
+	formatPNG     = "png"
+	formatJPEG    = "jpeg"
+	formatGIF     = "gif"
+	formatWebP    = "webp"
+	formatAVIF    = "avif"
+	formatMP4     = "mp4"
+	formatWebM    = "webm"
+)
+
+func sniffFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return formatPNG
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return formatJPEG
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return formatGIF
+	case bytes.HasPrefix(data, webmMagic):
+		return formatWebM
+	case len(data) >= 12 &&
+		bytes.Equal(data[:4], []byte("RIFF")) &&
+		bytes.Equal(data[8:12], []byte("WEBP")):
+		return formatWebP
+	case len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")):
+		// heic and unknown brands stay unknown
+		switch emote.SniffMedia(data) {
+		case emote.MediaAVIF:
+			return formatAVIF
+		case emote.MediaMP4:
+			return formatMP4
+		}
+		return formatUnknown
+	default:
+		return formatUnknown
+	}
+}
+
+// isAnimatedInput catches animation the source didn't report,
+// like animated webp or avif uploads. Videos are animated when the source
+// says so, a single frame video is drawn as a static image
+func isAnimatedInput(format string, data []byte) bool {
+	switch format {
+	case formatWebP:
+		return isAnimatedWebP(data)
+	case formatAVIF:
+		return emote.IsAnimatedAVIF(data)
+	default:
+		return false
+	}
+}
+
+// extended webp files start with a VP8X chunk that has the animation flag
+func isAnimatedWebP(data []byte) bool {
+	const animationFlag = 0x02
+	if len(data) < 21 || !bytes.Equal(data[12:16], []byte("VP8X")) {
+		return false
+	}
+	size := binary.LittleEndian.Uint32(data[16:20])
+	return size >= 1 && data[20]&animationFlag != 0
+}
@@ -0,0 +1,65 @@
+package resize
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// ftyp builds an iso media header with the major and compatible brands
+func ftyp(major string, compatible ...string) []byte {
+	brands := major + "\x00\x00\x00\x00" + strings.Join(compatible, "")
+	box := make([]byte, 8, 8+len(brands))
+	binary.BigEndian.PutUint32(box, uint32(8+len(brands)))
+	copy(box[4:], "ftyp")
+	return append(box, brands...)
+}
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), formatPNG},
+		{"jpeg", []byte("\xff\xd8\xff\xdb"), formatJPEG},
+		{"gif", []byte("GIF89a"), formatGIF},
+		{"webm", []byte("\x1a\x45\xdf\xa3\x01"), formatWebM},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8L"), formatWebP},
+		{"avif", ftyp("avif", "mif1"), formatAVIF},
+		{"avif sequence", ftyp("avis", "msf1"), formatAVIF},
+		{"avif behind mif1", ftyp("mif1", "avif"), formatAVIF},
+		{"mp4", ftyp("isom", "iso2", "mp41"), formatMP4},
+		{"m4v", ftyp("M4V ", "mp42"), formatMP4},
+		{"heic", ftyp("heic", "mif1"), formatUnknown},
+		{"unknown brand", ftyp("crx "), formatUnknown},
+		{"garbage", []byte("hello"), formatUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := sniffFormat(tt.data); got != tt.want {
+			t.Errorf("%s: sniffFormat = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsAnimatedInput(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		// the source tells if a video is a single frame
+		{"mp4", ftyp("isom"), false},
+		{"webm", []byte("\x1a\x45\xdf\xa3"), false},
+		{"avif", ftyp("avif", "mif1"), false},
+		{"avif sequence", ftyp("avis", "msf1"), true},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isAnimatedInput(sniffFormat(tt.data), tt.data); got != tt.want {
+			t.Errorf("%s: isAnimatedInput = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
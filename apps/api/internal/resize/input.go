@@ -0,0 +1,233 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/gifscan"
+)
+
+// ffmpegInput is how runFFMPEG reads the source
+type ffmpegInput struct {
+	args []string // demuxer and decoder options ending with -i
+	info *videoInfo
+	// visible pixels of all frames, only known for decoded frames,
+	// that is animated webp and gifs that aren't too big
+	content image.Rectangle
+	// kept for pixel art detection, only set for decoded frames
+	firstFrame image.Image
+}
+
+// prepareInput writes the source to the temp dir with an extension
+// ffmpeg recognizes. Animated webp can't be read by ffmpeg, so its frames
+// are decoded here and passed as a png sequence with the original delays
+func prepareInput(
+	ctx context.Context,
+	tmpDir string,
+	input []byte,
+	format string,
+) (*ffmpegInput, error) {
+	if format == formatWebP {
+		seq := &frameSequence{dir: tmpDir}
+		maxLength := time.Duration(maxSourceDuration * float64(time.Second))
+		if err := decodeAnimatedWebP(ctx, input, maxLength, seq.add); err != nil {
+			return nil, fmt.Errorf("failed to decode animated webp: %w", err)
+		}
+		return seq.finish()
+	}
+
+	if format == formatUnknown {
+		format = formatGIF
+	}
+	inputPath := filepath.Join(tmpDir, "input."+format)
+	if err := os.WriteFile(inputPath, input, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input file: %w", err)
+	}
+
+	info, err := getVideoInfo(ctx, inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video info: %w", err)
+	}
+	if info.Duration > maxSourceDuration {
+		info.Duration = maxSourceDuration
+	}
+
+	args := inputDecoder(input)
+	args = append(args,
+		"-t", fmt.Sprintf("%.2f", maxSourceDuration),
+		"-i", inputPath,
+	)
+	source := &ffmpegInput{args: args, info: info}
+	if format == formatGIF {
+		if err := decodeGIF(input, source); err != nil {
+			return nil, err
+		}
+	}
+	return source, nil
+}
+
+// decodeGIF fixes the frame rate, ffprobe reports the gif timebase
+// instead. The frames are decoded once for trimming and pixel art
+// detection when they fit in memory, ffmpeg reads the rest on its own
+func decodeGIF(input []byte, source *ffmpegInput) error {
+	scan, err := gifscan.Scan(input)
+	if err != nil {
+		// ffmpeg is more forgiving with broken files
+		return nil
+	}
+	if scan.Width*scan.Height > maxSourcePixels {
+		return fmt.Errorf("gif canvas %dx%d is too big", scan.Width, scan.Height)
+	}
+	if fps, err := gifPeakFPS(scan.Delays); err == nil {
+		source.info.FPS = fps
+	}
+
+	if scan.Frames > maxSourceFrames || scan.FramePixels > maxDecodedPixels {
+		return nil
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(input))
+	if err != nil || len(g.Image) == 0 {
+		return nil
+	}
+	for _, frame := range g.Image {
+		source.content = source.content.Union(contentBounds(frame))
+	}
+	source.firstFrame = g.Image[0]
+	return nil
+}
+
+// frameSequence saves frames as pngs listed in an ffconcat file
+type frameSequence struct {
+	dir      string
+	list     strings.Builder
+	count    int
+	last     string
+	length   time.Duration
+	minDelay time.Duration
+	bounds   image.Rectangle
+	content  image.Rectangle
+	first    image.Image
+}
+
+func (s *frameSequence) add(frame animationFrame) error {
+	if s.count == 0 {
+		s.list.WriteString("ffconcat version 1.0\n")
+		s.bounds = frame.img.Bounds()
+		s.minDelay = frame.delay
+		s.first = frame.img
+	}
+
+	s.last = fmt.Sprintf("frame%04d.png", s.count)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, frame.img); err != nil {
+		return fmt.Errorf("failed to encode frame %d: %w", s.count, err)
+	}
+	path := filepath.Join(s.dir, s.last)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write frame %d: %w", s.count, err)
+	}
+
+	fmt.Fprintf(&s.list, "file '%s'\nduration %.3f\n", s.last, frame.delay.Seconds())
+	s.content = s.content.Union(contentBounds(frame.img))
+	s.length += frame.delay
+	if frame.delay < s.minDelay {
+		s.minDelay = frame.delay
+	}
+	s.count++
+	return nil
+}
+
+// finish writes the list, the last frame is repeated so its delay
+// isn't dropped
+func (s *frameSequence) finish() (*ffmpegInput, error) {
+	fmt.Fprintf(&s.list, "file '%s'\n", s.last)
+
+	listPath := filepath.Join(s.dir, "frames.txt")
+	if err := os.WriteFile(listPath, []byte(s.list.String()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write frame list: %w", err)
+	}
+
+	return &ffmpegInput{
+		args: []string{"-f", "concat", "-safe", "0", "-i", listPath},
+		info: &videoInfo{
+			FPS:      1 / s.minDelay.Seconds(),
+			Duration: s.length.Seconds(),
+			Width:    s.bounds.Dx(),
+			Height:   s.bounds.Dy(),
+		},
+		content:    s.content,
+		firstFrame: s.first,
+	}, nil
+}
+
+// gifPeakFPS is the frame rate of the shortest delay, delays under 20ms
+// are played as 100ms like ffmpeg and browsers do
+func gifPeakFPS(delays []int) (float64, error) {
+	minDelay := 0
+	for _, delay := range delays {
+		if delay < 2 {
+			delay = 10
+		}
+		if minDelay == 0 || delay < minDelay {
+			minDelay = delay
+		}
+	}
+	if minDelay == 0 {
+		return 0, fmt.Errorf("gif has no frames")
+	}
+	return 100 / float64(minDelay), nil
+}
+
+// decodeImage decodes a static image, avif and single frame videos go
+// through ffmpeg since there is no go decoder for them
+func decodeImage(ctx context.Context, input []byte) (image.Image, error) {
+	format := sniffFormat(input)
+	switch format {
+	case formatAVIF, formatMP4, formatWebM:
+	default:
+		imgConfig, _, err := image.DecodeConfig(bytes.NewReader(input))
+		if err != nil {
+			return nil, err
+		}
+		if imgConfig.Width*imgConfig.Height > maxSourcePixels {
+			return nil, fmt.Errorf(
+				"image %dx%d is too big", imgConfig.Width, imgConfig.Height,
+			)
+		}
+		img, _, err := image.Decode(bytes.NewReader(input))
+		return img, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "frameconv")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	inputPath := filepath.Join(tmpDir, "input."+format)
+	if err := os.WriteFile(inputPath, input, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input file: %w", err)
+	}
+
+	args := append(inputDecoder(input),
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-c:v", "png",
+		"-pix_fmt", "rgba",
+		"-f", "image2pipe",
+		"pipe:1",
+	)
+	out, err := ffmpeg(args...).output(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", format, err)
+	}
+
+	return png.Decode(bytes.NewReader(out))
+}
@@ -0,0 +1,101 @@
+package resize
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/gifscan"
+)
+
+// encodeGIF builds a gif with one frame per delay on a width x height
+// screen, frames are the given rectangles drawn in the opaque color
+func encodeGIF(t *testing.T, width, height int, delays []int, frames []image.Rectangle) []byte {
+	t.Helper()
+	palette := color.Palette{color.Transparent, color.White}
+	g := &gif.GIF{
+		Delay:  delays,
+		Config: image.Config{Width: width, Height: height, ColorModel: palette},
+	}
+	for _, rect := range frames {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				frame.SetColorIndex(x, y, 1)
+			}
+		}
+		g.Image = append(g.Image, frame)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGIFPeakFPS(t *testing.T) {
+	tests := []struct {
+		delays []int
+		fps    float64
+	}{
+		{[]int{10, 10}, 10},
+		{[]int{10, 5, 20}, 20},
+		// too short delays play at 10 fps
+		{[]int{0, 1}, 10},
+		{[]int{1, 4}, 25},
+	}
+
+	for _, tt := range tests {
+		frames := make([]image.Rectangle, len(tt.delays))
+		scan, err := gifscan.Scan(encodeGIF(t, 2, 2, tt.delays, frames))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fps, err := gifPeakFPS(scan.Delays)
+		if err != nil {
+			t.Errorf("delays %v: %v", tt.delays, err)
+			continue
+		}
+		if fps != tt.fps {
+			t.Errorf("delays %v: fps = %v, want %v", tt.delays, fps, tt.fps)
+		}
+	}
+}
+
+func TestDecodeGIF(t *testing.T) {
+	input := encodeGIF(t, 32, 32, []int{10, 5}, []image.Rectangle{
+		image.Rect(4, 4, 8, 8),
+		image.Rect(10, 2, 20, 6),
+	})
+	source := &ffmpegInput{info: &videoInfo{FPS: 100}}
+	if err := decodeGIF(input, source); err != nil {
+		t.Fatal(err)
+	}
+
+	if source.info.FPS != 20 {
+		t.Errorf("fps = %v, want 20", source.info.FPS)
+	}
+	if want := image.Rect(4, 2, 20, 8); source.content != want {
+		t.Errorf("content = %v, want %v", source.content, want)
+	}
+	if source.firstFrame == nil {
+		t.Error("first frame was not kept")
+	}
+}
+
+func TestDecodeGIFTooBig(t *testing.T) {
+	input := encodeGIF(t, 2, 2, []int{10}, []image.Rectangle{{}})
+	// the logical screen size sits right after the signature
+	input[6], input[7] = 0xff, 0xff
+	input[8], input[9] = 0xff, 0xff
+
+	source := &ffmpegInput{info: &videoInfo{}}
+	if err := decodeGIF(input, source); err == nil {
+		t.Error("a 65535x65535 gif was accepted")
+	}
+	if source.firstFrame != nil {
+		t.Error("frames were decoded")
+	}
+}
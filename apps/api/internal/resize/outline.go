@@ -0,0 +1,224 @@
+package resize
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+// shadow settings on a 512px sticker
+const (
+	outlineReference = 512
+	shadowOffset     = 6
+	shadowSigma      = 6
+	shadowOpacity    = 0.5
+	// alpha at which a pixel counts as inside for the stroke
+	outlineAlphaCutoff = 128
+)
+
+// outlineGeometry is the outline scaled to the output size
+type outlineGeometry struct {
+	width  int
+	offset int
+	sigma  float64
+	margin int // space around the art so nothing gets cut off
+	color  color.NRGBA
+	shadow bool
+}
+
+func newOutlineGeometry(o *emote.Outline, profile Profile) outlineGeometry {
+	scale := func(v int) int {
+		if v == 0 {
+			return 0
+		}
+		return max(1, v*profile.Size/outlineReference)
+	}
+
+	g := outlineGeometry{
+		width:  scale(o.Width),
+		color:  o.RGB(),
+		shadow: o.Shadow,
+	}
+	g.margin = g.width
+	if g.shadow {
+		g.offset = scale(shadowOffset)
+		g.sigma = float64(scale(shadowSigma))
+		g.margin += g.offset + int(math.Ceil(g.sigma*3))
+	}
+	return g
+}
+
+// outlineMargin is how much the art has to shrink to fit the outline
+func outlineMargin(o *emote.Outline, profile Profile) int {
+	if !o.Visible() {
+		return 0
+	}
+	return newOutlineGeometry(o, profile).margin
+}
+
+// applyOutline grows the image by the margin and draws the stroke and
+// the shadow under it
+func applyOutline(img *image.NRGBA, o *emote.Outline, profile Profile) *image.NRGBA {
+	g := newOutlineGeometry(o, profile)
+	bounds := img.Bounds()
+	layer := imaging.New(
+		bounds.Dx()+2*g.margin, bounds.Dy()+2*g.margin, color.Transparent,
+	)
+	layer = imaging.Paste(layer, img, image.Pt(g.margin, g.margin))
+
+	if g.width > 0 {
+		stroke := strokeLayer(layer, g.width, g.color)
+		draw.Draw(stroke, stroke.Bounds(), layer, image.Point{}, draw.Over)
+		layer = stroke
+	}
+	if !g.shadow {
+		return layer
+	}
+
+	shadow := image.NewNRGBA(layer.Bounds())
+	for i := 3; i < len(layer.Pix); i += 4 {
+		shadow.Pix[i] = uint8(float64(layer.Pix[i]) * shadowOpacity)
+	}
+	shadow = imaging.Blur(shadow, g.sigma)
+
+	result := image.NewNRGBA(layer.Bounds())
+	draw.Draw(
+		result, result.Bounds().Add(image.Pt(g.offset, g.offset)),
+		shadow, image.Point{}, draw.Src,
+	)
+	draw.Draw(result, result.Bounds(), layer, image.Point{}, draw.Over)
+	return result
+}
+
+// strokeLayer is a solid color silhouette of the image dilated by width,
+// the edge is antialiased with the distance to the art
+func strokeLayer(img *image.NRGBA, width int, c color.NRGBA) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	inside := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			inside[y*w+x] = img.Pix[img.PixOffset(x, y)+3] >= outlineAlphaCutoff
+		}
+	}
+	dist := distanceTransform(inside, w, h)
+
+	stroke := image.NewNRGBA(bounds)
+	for i, d := range dist {
+		coverage := math.Min(1, math.Max(0, float64(width)+0.5-math.Sqrt(d)))
+		if coverage == 0 {
+			continue
+		}
+		stroke.Pix[i*4] = c.R
+		stroke.Pix[i*4+1] = c.G
+		stroke.Pix[i*4+2] = c.B
+		stroke.Pix[i*4+3] = uint8(coverage * 255)
+	}
+	return stroke
+}
+
+// distanceTransform returns the squared euclidean distance of every pixel
+// to the closest inside pixel (Felzenszwalb and Huttenlocher)
+func distanceTransform(inside []bool, w, h int) []float64 {
+	inf := float64(w*w + h*h)
+	dist := make([]float64, w*h)
+	for i, in := range inside {
+		if !in {
+			dist[i] = inf
+		}
+	}
+
+	column := make([]float64, h)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			column[y] = dist[y*w+x]
+		}
+		column = distanceTransform1D(column)
+		for y := 0; y < h; y++ {
+			dist[y*w+x] = column[y]
+		}
+	}
+	for y := 0; y < h; y++ {
+		copy(dist[y*w:(y+1)*w], distanceTransform1D(dist[y*w:(y+1)*w]))
+	}
+	return dist
+}
+
+func distanceTransform1D(f []float64) []float64 {
+	n := len(f)
+	d := make([]float64, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+	k := 0
+	z[0], z[1] = math.Inf(-1), math.Inf(1)
+
+	intersection := func(q, p int) float64 {
+		return ((f[q] + float64(q*q)) - (f[p] + float64(p*p))) / float64(2*q-2*p)
+	}
+	for q := 1; q < n; q++ {
+		s := intersection(q, v[k])
+		for s <= z[k] {
+			k--
+			s = intersection(q, v[k])
+		}
+		k++
+		v[k] = q
+		z[k] = s
+		z[k+1] = math.Inf(1)
+	}
+
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		d[q] = float64((q-v[k])*(q-v[k])) + f[v[k]]
+	}
+	return d
+}
+
+// outlineFilter is the ffmpeg equivalent of applyOutline, the alpha is
+// dilated one pixel at a time alternating square and cross kernels so
+// the stroke has rounded corners
+func outlineFilter(o *emote.Outline, profile Profile) string {
+	g := newOutlineGeometry(o, profile)
+
+	var filter strings.Builder
+	fmt.Fprintf(&filter,
+		"format=rgba,pad=iw+%[1]d:ih+%[1]d:%[2]d:%[2]d:color=0x00000000",
+		2*g.margin, g.margin,
+	)
+
+	if g.width > 0 {
+		fmt.Fprintf(&filter,
+			",split[art][mask];[mask]lutrgb=r=%d:g=%d:b=%d:a='if(gte(val,%d),255,0)',format=gbrap",
+			g.color.R, g.color.G, g.color.B, outlineAlphaCutoff,
+		)
+		for i := 0; i < g.width; i++ {
+			if i%2 == 0 {
+				filter.WriteString(",dilation")
+			} else {
+				filter.WriteString(",dilation=coordinates=90")
+			}
+		}
+		filter.WriteString(",format=rgba[stroke];[stroke][art]overlay=format=auto")
+	}
+
+	if g.shadow {
+		// pad and crop shift the shadow down and right
+		fmt.Fprintf(&filter,
+			",split[top][shadow];[shadow]lutrgb=r=0:g=0:b=0:a=val*%.2f,gblur=sigma=%.1f,"+
+				"pad=iw+%[3]d:ih+%[3]d:%[3]d:%[3]d:color=0x00000000,crop=iw-%[3]d:ih-%[3]d:0:0[below];"+
+				"[below][top]overlay=format=auto",
+			shadowOpacity, g.sigma, g.offset,
+		)
+	}
+	return filter.String()
+}
@@ -0,0 +1,101 @@
+package resize
+
+import (
+	"image/color"
+	"slices"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+func TestOutlineGeometry(t *testing.T) {
+	tests := []struct {
+		name    string
+		outline emote.Outline
+		profile Profile
+		width   int
+		offset  int
+		margin  int
+	}{
+		{"sticker", emote.Outline{Width: 12}, StickerProfile, 12, 0, 12},
+		{"emoji is scaled down", emote.Outline{Width: 12}, EmojiProfile, 2, 0, 2},
+		{"thin emoji stroke stays visible", emote.Outline{Width: 1}, EmojiProfile, 1, 0, 1},
+		// the shadow needs its offset and three sigmas of blur
+		{"sticker shadow", emote.Outline{Width: 8, Shadow: true}, StickerProfile, 8, 6, 32},
+		{"emoji shadow only", emote.Outline{Shadow: true}, EmojiProfile, 0, 1, 4},
+	}
+
+	for _, tt := range tests {
+		g := newOutlineGeometry(&tt.outline, tt.profile)
+		if g.width != tt.width || g.offset != tt.offset || g.margin != tt.margin {
+			t.Errorf(
+				"%s: width %d offset %d margin %d, want %d %d %d",
+				tt.name, g.width, g.offset, g.margin, tt.width, tt.offset, tt.margin,
+			)
+		}
+		if got := outlineMargin(&tt.outline, tt.profile); got != tt.margin {
+			t.Errorf("%s: outlineMargin = %d, want %d", tt.name, got, tt.margin)
+		}
+	}
+
+	if got := outlineMargin(nil, StickerProfile); got != 0 {
+		t.Errorf("outlineMargin(nil) = %d, want 0", got)
+	}
+	if got := outlineMargin(&emote.Outline{}, StickerProfile); got != 0 {
+		t.Errorf("outlineMargin of an empty outline = %d, want 0", got)
+	}
+}
+
+func TestDistanceTransform(t *testing.T) {
+	tests := []struct {
+		name   string
+		inside []bool
+		w, h   int
+		want   []float64
+	}{
+		{"row", []bool{false, false, true, false, false}, 5, 1, []float64{4, 1, 0, 1, 4}},
+		{
+			"center",
+			[]bool{
+				false, false, false,
+				false, true, false,
+				false, false, false,
+			},
+			3, 3,
+			[]float64{2, 1, 2, 1, 0, 1, 2, 1, 2},
+		},
+		{"two points", []bool{true, false, false, false, true}, 5, 1, []float64{0, 1, 4, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := distanceTransform(tt.inside, tt.w, tt.h); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: distanceTransform = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyOutline(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	img := imaging.New(10, 10, red)
+	outlined := applyOutline(img, &emote.Outline{Width: 4}, StickerProfile)
+
+	if got := outlined.Bounds().Size(); got.X != 18 || got.Y != 18 {
+		t.Fatalf("applyOutline size = %v, want 18x18", got)
+	}
+	tests := []struct {
+		name string
+		x, y int
+		want color.NRGBA
+	}{
+		{"art", 8, 8, red},
+		{"stroke", 1, 8, color.NRGBA{255, 255, 255, 255}},
+		{"rounded corner", 0, 0, color.NRGBA{}},
+	}
+
+	for _, tt := range tests {
+		if got := outlined.NRGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s: pixel at %d,%d = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
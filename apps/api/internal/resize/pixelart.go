@@ -0,0 +1,127 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// pixel art rarely uses more colors than this
+	maxPixelArtColors = 64
+	// share of semi-transparent pixels, antialiased art has a lot more
+	maxSoftEdgeShare = 0.02
+	// smaller upscales look fine with the usual filter
+	minPixelArtScale = 2
+)
+
+// isPixelArt reports if the image looks like pixel art, it has few
+// colors and hard transparency edges
+func isPixelArt(img image.Image) bool {
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return false
+	}
+
+	colors := make(map[color.NRGBA]struct{}, maxPixelArtColors+1)
+	soft := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			switch c.A {
+			case 0:
+				continue
+			case 255:
+			default:
+				soft++
+			}
+			colors[c] = struct{}{}
+			if len(colors) > maxPixelArtColors {
+				return false
+			}
+		}
+	}
+	return float64(soft) <= maxSoftEdgeShare*float64(total)
+}
+
+// animatedPixelArt checks the first frame, lossy video never has the
+// clean edges of pixel art
+func animatedPixelArt(format string, source *ffmpegInput) bool {
+	switch format {
+	case formatWebP, formatGIF:
+		return source.firstFrame != nil && isPixelArt(source.firstFrame)
+	}
+	return false
+}
+
+// pixelScale is the whole multiple a width x height source is scaled by,
+// 0 means the resample filter is used instead. detect only runs when the
+// filter is left on auto
+func pixelScale(opts emote.Options, width, height, inner int, detect func() bool) int {
+	scale := inner / max(width, height)
+	if opts.Fit == emote.FitCover {
+		short := min(width, height)
+		scale = (inner + short - 1) / short
+	}
+
+	switch opts.Resample {
+	case emote.ResampleNearest:
+		return scale
+	case emote.ResampleAuto:
+		if scale >= minPixelArtScale && detect() {
+			return scale
+		}
+	}
+	return 0
+}
+
+// pixelCoverRect is the middle of a width x height source that is still
+// visible once cover scales it up, the rest would be cut off after
+// scaling so it's cut before, thin sources would get huge otherwise
+func pixelCoverRect(width, height, inner, scale int) image.Rectangle {
+	visible := (inner + scale - 1) / scale
+	w, h := min(width, visible), min(height, visible)
+	x, y := (width-w)/2, (height-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// pixelCanvas is the size the scaled pixel art is centered on, cover
+// crops the middle and contain pads the longest side up to inner
+func pixelCanvas(opts emote.Options, width, height, inner int) (int, int) {
+	if opts.Fit == emote.FitCover {
+		return inner, inner
+	}
+	if width >= height {
+		return inner, height
+	}
+	return width, inner
+}
+
+// resampleFilter is the imaging filter for the option
+func resampleFilter(resample string) imaging.ResampleFilter {
+	switch resample {
+	case emote.ResampleBicubic:
+		return imaging.CatmullRom
+	case emote.ResampleBilinear:
+		return imaging.Linear
+	case emote.ResampleNearest:
+		return imaging.NearestNeighbor
+	}
+	return imaging.Lanczos
+}
+
+// scaleFlags is the ffmpeg equivalent of resampleFilter
+func scaleFlags(resample string) string {
+	switch resample {
+	case emote.ResampleBicubic:
+		return "bicubic"
+	case emote.ResampleBilinear:
+		return "bilinear"
+	case emote.ResampleNearest:
+		return "neighbor"
+	}
+	return "lanczos"
+}
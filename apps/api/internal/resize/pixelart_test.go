@@ -0,0 +1,119 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+func TestPixelScale(t *testing.T) {
+	nearest := emote.Options{Resample: emote.ResampleNearest}
+	cover := emote.Options{Resample: emote.ResampleNearest, Fit: emote.FitCover}
+	auto := emote.Options{}
+
+	tests := []struct {
+		name          string
+		opts          emote.Options
+		width, height int
+		pixelArt      bool
+		want          int
+	}{
+		{"contain fits the long side", nearest, 32, 16, false, 16},
+		{"contain rounds down", nearest, 100, 50, false, 5},
+		{"cover fills the short side", cover, 32, 16, false, 32},
+		{"cover rounds up", cover, 100, 50, false, 11},
+		{"thin cover", cover, 4096, 8, false, 64},
+		{"auto pixel art", auto, 32, 32, true, 16},
+		{"auto not pixel art", auto, 32, 32, false, 0},
+		{"auto too big to matter", auto, 300, 300, true, 0},
+		{"bicubic", emote.Options{Resample: emote.ResampleBicubic}, 32, 32, true, 0},
+	}
+
+	for _, tt := range tests {
+		got := pixelScale(tt.opts, tt.width, tt.height, 512, func() bool {
+			return tt.pixelArt
+		})
+		if got != tt.want {
+			t.Errorf("%s: pixelScale = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPixelCoverRect(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		scale         int
+		want          image.Rectangle
+	}{
+		{"fits exactly", 32, 32, 16, image.Rect(0, 0, 32, 32)},
+		{"wide", 64, 32, 16, image.Rect(16, 0, 48, 32)},
+		{"tall", 32, 64, 16, image.Rect(0, 16, 32, 48)},
+		{"rounds up", 100, 50, 11, image.Rect(26, 1, 73, 48)},
+		{"thin", 4096, 8, 64, image.Rect(2044, 0, 2052, 8)},
+	}
+
+	for _, tt := range tests {
+		got := pixelCoverRect(tt.width, tt.height, 512, tt.scale)
+		if got != tt.want {
+			t.Errorf("%s: pixelCoverRect = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPixelCanvas(t *testing.T) {
+	tests := []struct {
+		name          string
+		fit           string
+		width, height int
+		wantW, wantH  int
+	}{
+		{"contain wide", emote.FitContain, 512, 256, 512, 256},
+		{"contain tall", emote.FitContain, 496, 512, 496, 512},
+		{"contain padded to inner", emote.FitContain, 500, 250, 512, 250},
+		{"cover", emote.FitCover, 1024, 512, 512, 512},
+	}
+
+	for _, tt := range tests {
+		opts := emote.Options{Fit: tt.fit}
+		w, h := pixelCanvas(opts, tt.width, tt.height, 512)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf(
+				"%s: pixelCanvas = %dx%d, want %dx%d",
+				tt.name, w, h, tt.wantW, tt.wantH,
+			)
+		}
+	}
+}
+
+// a thin strip with cover is cut to what shows before it's scaled up
+func TestPixelArtThinCover(t *testing.T) {
+	opts := emote.Options{Resample: emote.ResampleNearest, Fit: emote.FitCover}
+
+	img := imaging.New(4096, 8, color.NRGBA{255, 0, 0, 255})
+	fitted, err := fitImage(img, opts, StickerProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := fitted.Bounds().Size(); size != image.Pt(512, 512) {
+		t.Errorf("fitImage size = %v, want 512x512", size)
+	}
+
+	info := &videoInfo{Width: 4096, Height: 8}
+	filter, size, err := videoFilter(
+		opts, StickerProfile, info, image.Rectangle{}, nil, t.TempDir(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != image.Pt(512, 512) {
+		t.Errorf("videoFilter size = %v, want 512x512", size)
+	}
+	want := "crop=8:8:2044:0,scale=512:512:flags=neighbor,format=rgba,crop=512:512"
+	if filter != want {
+		t.Errorf("videoFilter = %q, want %q", filter, want)
+	}
+}
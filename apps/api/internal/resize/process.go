@@ -0,0 +1,159 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
+)
+
+const (
+	// stderr kept in memory, bbox logs a line per frame
+	maxStderrSize = 4 * 1024 * 1024
+	// stderr quoted in errors
+	stderrTailSize = 512
+)
+
+var errProcessTimeout = errors.New("process timed out")
+
+var (
+	slotsOnce    sync.Once
+	processSlots chan struct{}
+	// ulimit only exists as a shell builtin, sh execs the tool afterwards
+	// so killing the process kills the tool
+	memoryLimitScript = `ulimit -v "$0" && exec "$@"`
+)
+
+// ProcessError is a failed ffmpeg or ffprobe run with the end of its log
+type ProcessError struct {
+	Tool   string
+	Err    error
+	Stderr string
+}
+
+func (e *ProcessError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s failed: %v", e.Tool, e.Err)
+	}
+	return fmt.Sprintf("%s failed: %v: %s", e.Tool, e.Err, e.Stderr)
+}
+
+func (e *ProcessError) Unwrap() error {
+	return e.Err
+}
+
+// slots limits the processes running at once, the size is read from the
+// config on first use
+func slots() chan struct{} {
+	slotsOnce.Do(func() {
+		processSlots = make(chan struct{}, config.Load().FFmpegProcesses())
+	})
+	return processSlots
+}
+
+// process is a single ffmpeg or ffprobe run
+type process struct {
+	tool   string
+	args   []string
+	stdin  io.Reader
+	stdout io.Writer
+	// stderr is copied here as well, it's always captured for errors
+	stderr io.Writer
+}
+
+// run waits for a free slot and runs the tool with the configured time
+// and memory limits, cancelling the context kills the process
+func (p *process) run(ctx context.Context) error {
+	select {
+	case slots() <- struct{}{}:
+		defer func() { <-processSlots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	cfg := config.Load()
+	ctx, cancel := context.WithTimeoutCause(ctx, cfg.FFmpegTimeout(), errProcessTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.tool, p.args...)
+	if limit := cfg.FFmpegMemoryLimit(); limit > 0 {
+		kilobytes := strconv.FormatInt(limit/1024, 10)
+		args := append([]string{"-c", memoryLimitScript, kilobytes, p.tool}, p.args...)
+		cmd = exec.CommandContext(ctx, "sh", args...)
+	}
+	// pipes of a killed process are closed instead of waited on
+	cmd.WaitDelay = time.Second
+
+	stderr := &limitedBuffer{limit: maxStderrSize}
+	cmd.Stdin = p.stdin
+	cmd.Stdout = p.stdout
+	cmd.Stderr = stderr
+	if p.stderr != nil {
+		cmd.Stderr = io.MultiWriter(stderr, p.stderr)
+	}
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	// the parent context can have its own deadline, only ours is reported
+	// as a timeout of the process
+	if errors.Is(context.Cause(ctx), errProcessTimeout) {
+		err = fmt.Errorf("killed after %v: %w", cfg.FFmpegTimeout(), ctx.Err())
+	} else if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return &ProcessError{
+		Tool:   p.tool,
+		Err:    err,
+		Stderr: tail(stderr.String(), stderrTailSize),
+	}
+}
+
+// output runs the process and returns stdout
+func (p *process) output(ctx context.Context) ([]byte, error) {
+	var stdout bytes.Buffer
+	p.stdout = &stdout
+	if err := p.run(ctx); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+func ffmpeg(args ...string) *process {
+	common := []string{"-nostdin", "-hide_banner", "-nostats"}
+	return &process{tool: "ffmpeg", args: append(common, args...)}
+}
+
+func ffprobe(args ...string) *process {
+	return &process{tool: "ffprobe", args: args}
+}
+
+// limitedBuffer drops everything past the limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func tail(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
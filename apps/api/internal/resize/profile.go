@@ -0,0 +1,20 @@
+package resize
+
+import "fmt"
+
+// Profile is the output size telegram expects for a sticker type
+type Profile struct {
+	// longest side of the output
+	Size int
+	// pad to Size x Size, custom emoji have to be exactly square
+	Square bool
+}
+
+var (
+	StickerProfile = Profile{Size: 512}
+	EmojiProfile   = Profile{Size: 100, Square: true}
+)
+
+func (p Profile) key() string {
+	return fmt.Sprintf("%dx%t", p.Size, p.Square)
+}
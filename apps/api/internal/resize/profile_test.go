@@ -0,0 +1,45 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+func TestProfileFitImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		profile       Profile
+		width, height int
+		want          image.Point
+	}{
+		{"sticker wide", StickerProfile, 300, 150, image.Pt(512, 256)},
+		{"sticker tall", StickerProfile, 150, 300, image.Pt(256, 512)},
+		{"emoji wide is padded", EmojiProfile, 300, 150, image.Pt(100, 100)},
+		{"emoji tall is padded", EmojiProfile, 150, 300, image.Pt(100, 100)},
+		{"emoji square", EmojiProfile, 32, 32, image.Pt(100, 100)},
+	}
+
+	for _, tt := range tests {
+		img := imaging.New(tt.width, tt.height, color.NRGBA{0, 128, 255, 255})
+		fitted, err := fitImage(img, emote.Options{}, tt.profile)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := fitted.Bounds().Size(); got != tt.want {
+			t.Errorf("%s: fitImage size = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// emoji and stickers from the same emote are cached separately
+func TestSettingsKeyProfile(t *testing.T) {
+	opts := emote.Options{}
+	if SettingsKey(opts, StickerProfile) == SettingsKey(opts, EmojiProfile) {
+		t.Error("sticker and emoji profiles share a settings key")
+	}
+}
@@ -2,114 +2,222 @@ package resize
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
-	"github.com/disintegration/imaging"
 	"image"
 	"image/png"
+	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	_ "golang.org/x/image/webp" // uploaded and telegram stickers
 )
 
 const (
 	maxVideoSize = 256 * 1024 // 256 KB
 	maxFPS       = 30
 	maxDuration  = 3.0
+	// longer sources are cut before the timing options are applied
+	maxSourceDuration = 30.0
+	// sources are rejected before decoding when a frame is bigger than
+	// this or there are more frames than 30s at 100 fps
+	maxSourcePixels = 4096 * 4096
+	maxSourceFrames = 3000
+	// frames decoded in go add up to at most this many pixels, bigger
+	// gifs are left to ffmpeg and bigger animated webp are rejected
+	maxDecodedPixels = 64 * 1024 * 1024
+)
+
+// bump when the output changes, cached results depend on it
+const encoderVersion = 14
+
+var (
+	numCPUs   = runtime.NumCPU()
+	webmMagic = []byte("\x1a\x45\xdf\xa3")
 )
 
-var numCPUs = runtime.NumCPU()
+// SettingsKey identifies the encoder settings, the output profile and
+// the sticker options for caching fitted emotes
+func SettingsKey(opts emote.Options, profile Profile) string {
+	optsJSON, _ := json.Marshal(opts)
+	return fmt.Sprintf(
+		"v%d:%s:%d:%d:%d:%.1f:%s",
+		encoderVersion,
+		profile.key(),
+		maxVideoSize,
+		maxStaticSize,
+		maxFPS,
+		maxDuration,
+		optsJSON,
+	)
+}
+
+// FitEmote resizes the emote in place to the profile, emotes that turn
+// out to be animated are marked as such
+func FitEmote(
+	ctx context.Context,
+	data *emote.EmoteData,
+	opts emote.Options,
+	profile Profile,
+) error {
+	if !data.Text {
+		format := sniffFormat(data.File)
+		if data.Animated || isAnimatedInput(format, data.File) {
+			resizedWebm, err := fitAnimated(ctx, data.File, format, opts, profile)
+			if err != nil {
+				return fmt.Errorf("error resizing emote: %w", err)
+			}
+			data.File = resizedWebm
+			data.Animated = true
+			return nil
+		}
+	}
 
-func FitEmote(emote *emote.EmoteData) error {
-	if emote.Animated {
-		resizedWebm, err := fitGIF(emote.File)
+	var resizedPng []byte
+	var err error
+	if data.Text {
+		resizedPng, err = fitText(string(data.File), opts, profile)
+	} else {
+		resizedPng, err = fitPNG(ctx, data.File, opts, profile)
+	}
+	if err != nil {
+		return fmt.Errorf("error resizing emote: %w", err)
+	}
+	data.Text = false
+
+	if len(opts.Effects) > 0 {
+		video, err := animateStatic(ctx, resizedPng, opts.Effects)
 		if err != nil {
-			return fmt.Errorf("error resizing emote: %w", err)
+			return fmt.Errorf("error animating emote: %w", err)
 		}
-		emote.File = resizedWebm
+		data.File = video
+		data.Animated = true
 		return nil
 	}
 
-	resizedPng, err := fitPNG(emote.File)
+	output, err := encodeStatic(ctx, resizedPng, opts.StaticFormat)
 	if err != nil {
-		return fmt.Errorf("error resizing emote: %w", err)
+		return fmt.Errorf("error encoding emote: %w", err)
 	}
-	emote.File = resizedPng
+	data.File = output
 
 	return nil
 }
 
-func fitPNG(input []byte) ([]byte, error) {
-	img, _, err := image.Decode(bytes.NewReader(input))
+func fitPNG(
+	ctx context.Context,
+	input []byte,
+	opts emote.Options,
+	profile Profile,
+) ([]byte, error) {
+	img, err := decodeImage(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	fitted, err := fitImage(img, opts, profile)
 	if err != nil {
 		return nil, err
 	}
 
-	srcBounds := img.Bounds()
-	width := srcBounds.Dx()
-	height := srcBounds.Dy()
+	var buf bytes.Buffer
+	err = png.Encode(&buf, fitted)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-	var newImg *image.NRGBA
-	if width >= height {
-		newImg = imaging.Resize(img, 512, 0, imaging.Lanczos)
-	} else {
-		newImg = imaging.Resize(img, 0, 512, imaging.Lanczos)
+// fitText renders a text-only sticker to a png like fitPNG
+func fitText(text string, opts emote.Options, profile Profile) ([]byte, error) {
+	img, err := renderTextSticker(text, opts, profile)
+	if err != nil {
+		return nil, err
+	}
+	fitted, err := fitImage(img, opts, profile)
+	if err != nil {
+		return nil, err
 	}
 
 	var buf bytes.Buffer
-	err = png.Encode(&buf, newImg)
-	if err != nil {
+	if err := png.Encode(&buf, fitted); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
-func fitGIF(input []byte) ([]byte, error) {
+func fitAnimated(
+	ctx context.Context,
+	input []byte,
+	format string,
+	opts emote.Options,
+	profile Profile,
+) ([]byte, error) {
 	tmpDir, err := os.MkdirTemp("", "gifconv")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
-	inputPath := filepath.Join(tmpDir, "input.gif")
-	if err := os.WriteFile(inputPath, input, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write input file: %w", err)
-	}
-	outputPath := filepath.Join(tmpDir, "output.webm")
 
-	info, err := getVideoInfo(inputPath)
+	source, err := prepareInput(ctx, tmpDir, input, format)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get video info: %w", err)
+		return nil, err
 	}
 
-	fps := capFPS(info.FPS)
-	duration := capDuration(info.Duration)
-
-	encodingAttempts := encodingAttempts(duration)
-	for i, config := range encodingAttempts {
-		if err := runFFMPEG(fps, duration, inputPath, outputPath, config); err != nil {
-			if i == len(encodingAttempts)-1 {
-				return nil, fmt.Errorf("all ffmpeg attempts failed, last error: %w", err)
-			}
-			continue
-		}
-
-		output, err := os.ReadFile(outputPath)
+	background := ""
+	if opts.RemoveBackground != nil {
+		background, err = backgroundFilter(ctx, opts.RemoveBackground, source)
 		if err != nil {
-			continue
-		}
-		if len(output) <= maxVideoSize {
-			return output, nil
+			return nil, err
 		}
+	}
 
-		if i == len(encodingAttempts)-1 {
-			return nil, fmt.Errorf("output exceeds 256KB after all attempts: %d bytes", len(output))
-		}
+	var content image.Rectangle
+	if opts.Trims() {
+		content = animatedContentBounds(ctx, format, source, background)
 	}
+	transforms, geo, err := transformFilter(opts.Transforms, frameGeometry{
+		width:   source.info.Width,
+		height:  source.info.Height,
+		content: content,
+	})
+	if err != nil {
+		return nil, err
+	}
+	info := *source.info
+	info.Width, info.Height = geo.width, geo.height
 
-	return nil, fmt.Errorf("failed to create valid output")
+	filter, frameSize, err := videoFilter(opts, profile, &info, geo.content, func() bool {
+		return animatedPixelArt(format, source)
+	}, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	if transforms != "" {
+		filter = transforms + "," + filter
+	}
+	if background != "" {
+		filter = background + "," + filter
+	}
+	timing, err := timingFilter(opts, source.info)
+	if err != nil {
+		return nil, err
+	}
+	if timing.filter != "" {
+		filter += "," + timing.filter
+	}
+
+	output, report, err := encodeVideo(
+		ctx, tmpDir, source, filter, frameSize, timing,
+	)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("encoded video sticker: %s", report)
+	return output, nil
 }
 
 type videoInfo struct {
@@ -119,19 +227,6 @@ type videoInfo struct {
 	Height   int
 }
 
-type encodingConfig struct {
-	bitrate string
-	crf     int
-	cpuUsed int
-}
-
-func capFPS(fps float64) float64 {
-	if fps > maxFPS || fps == 0 {
-		return maxFPS
-	}
-	return fps
-}
-
 func capDuration(duration float64) float64 {
 	if duration > maxDuration {
 		return maxDuration
@@ -139,94 +234,63 @@ func capDuration(duration float64) float64 {
 	return duration
 }
 
-func encodingAttempts(duration float64) []encodingConfig {
-	return []encodingConfig{
-		{bitrate: calculateTargetBitrate(duration, 0.85), crf: 32, cpuUsed: 1},
-		{bitrate: calculateTargetBitrate(duration, 0.75), crf: 35, cpuUsed: 2},
-		{bitrate: calculateTargetBitrate(duration, 0.65), crf: 40, cpuUsed: 3},
-		{bitrate: calculateTargetBitrate(duration, 0.55), crf: 45, cpuUsed: 4},
-	}
-}
-
-func getVideoInfo(inputPath string) (*videoInfo, error) {
-	cmd := exec.Command("ffprobe",
-		"-v", "quiet",
+// getVideoInfo reads the first video stream, webm streams have no
+// duration so the container duration is used as a fallback
+func getVideoInfo(ctx context.Context, inputPath string) (*videoInfo, error) {
+	out, err := ffprobe(
+		"-v", "error",
 		"-select_streams", "v:0",
-		"-show_entries", "stream=r_frame_rate,width,height,duration",
-		"-of", "csv=p=0",
+		"-show_entries", "stream=r_frame_rate,width,height,duration:format=duration",
+		"-of", "default=noprint_wrappers=1",
 		inputPath,
-	)
-	out, err := cmd.Output()
+	).output(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	parts := strings.Split(strings.TrimSpace(string(out)), ",")
-	if len(parts) < 4 {
-		return nil, fmt.Errorf("invalid ffprobe output: %s", out)
-	}
-
 	info := &videoInfo{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
 
-	// FPS is a fraction
-	fpsParts := strings.Split(parts[0], "/")
-	if len(fpsParts) == 2 {
-		num, _ := strconv.ParseFloat(fpsParts[0], 64)
-		den, _ := strconv.ParseFloat(fpsParts[1], 64)
-		if den != 0 {
-			info.FPS = num / den
+		switch key {
+		case "r_frame_rate":
+			// FPS is a fraction
+			num, den, ok := strings.Cut(value, "/")
+			if !ok {
+				continue
+			}
+			numerator, _ := strconv.ParseFloat(num, 64)
+			denominator, _ := strconv.ParseFloat(den, 64)
+			if denominator != 0 {
+				info.FPS = numerator / denominator
+			}
+		case "width":
+			info.Width, _ = strconv.Atoi(value)
+		case "height":
+			info.Height, _ = strconv.Atoi(value)
+		case "duration":
+			// the stream duration comes first, N/A doesn't parse
+			if info.Duration == 0 {
+				info.Duration, _ = strconv.ParseFloat(value, 64)
+			}
 		}
 	}
 
-	info.Width, _ = strconv.Atoi(parts[1])
-	info.Height, _ = strconv.Atoi(parts[2])
-
-	info.Duration, _ = strconv.ParseFloat(parts[3], 64)
-
+	if info.Width == 0 || info.Height == 0 {
+		return nil, fmt.Errorf("invalid ffprobe output: %s", out)
+	}
 	return info, nil
 }
 
-func calculateTargetBitrate(duration float64, efficiency float64) string {
-	targetBits := float64(maxVideoSize) * efficiency * 8
-	bitrate := int(targetBits / duration)
-	return fmt.Sprintf("%dk", bitrate/1000)
-}
-
-func runFFMPEG(fps, duration float64, inputPath, outputPath string, config encodingConfig) error {
-	threads := fmt.Sprintf("%d", min(numCPUs, 4)) // cap at 4 threads
-
-	cmd := exec.Command("ffmpeg",
-		"-y",
-		"-i", inputPath,
-		"-t", fmt.Sprintf("%.2f", duration),
-		"-r", fmt.Sprintf("%.0f", fps),
-		"-vf", "scale='if(gt(a,1),512,-1)':'if(gt(a,1),-1,512)'",
-		"-c:v", "libvpx-vp9",
-		"-pix_fmt", "yuva420p",
-		"-crf", fmt.Sprintf("%d", config.crf),
-		"-b:v", config.bitrate,
-		"-maxrate", config.bitrate,
-		"-bufsize", fmt.Sprintf("%dk", parseBitrate(config.bitrate)*2),
-		"-an", // No audio
-		"-threads", threads,
-		"-row-mt", "1",
-		"-tile-columns", "2",
-		"-quality", "good",
-		"-cpu-used", fmt.Sprintf("%d", config.cpuUsed),
-		"-static-thresh", "0",
-		"-auto-alt-ref", "1",
-		"-lag-in-frames", "16",
-		"-f", "webm",
-		outputPath,
-	)
-
-	return cmd.Run()
-}
-
-func parseBitrate(bitrateStr string) int {
-	numStr := strings.TrimSuffix(bitrateStr, "k")
-	val, _ := strconv.Atoi(numStr)
-	return val
+// inputDecoder forces libvpx for webm, the native vp9 decoder drops alpha
+func inputDecoder(input []byte) []string {
+	if bytes.HasPrefix(input, webmMagic) {
+		return []string{"-c:v", "libvpx-vp9"}
+	}
+	return nil
 }
 
 func min(a, b int) int {
@@ -0,0 +1,376 @@
+package resize
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// text sizes on a 512px sticker
+const (
+	minTextSize = 14.0
+	// the size shrinks by this step until the text fits
+	textSizeStep    = 0.9
+	textLineSpacing = 1.1
+	// stroke width relative to the font size
+	textStrokeShare = 0.08
+	// captions take at most this share of the height
+	captionShare = 0.3
+)
+
+const (
+	alignTop = iota
+	alignCenter
+	alignBottom
+)
+
+var bundledFonts = map[string][]byte{
+	emote.FontBold:    gobold.TTF,
+	emote.FontRegular: goregular.TTF,
+	emote.FontMono:    gomonobold.TTF,
+}
+
+var (
+	fontsOnce   sync.Once
+	parsedFonts map[string]*sfnt.Font
+	emojiFont   *sfnt.Font
+)
+
+// loadFonts parses the bundled fonts and the configured emoji font,
+// text is still drawn without emoji when it can't be loaded
+func loadFonts() {
+	fontsOnce.Do(func() {
+		parseBundledFonts()
+
+		path := config.Load().EmojiFontPath()
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			emojiFont, err = opentype.Parse(data)
+		}
+		if err != nil {
+			log.Printf("emoji font %s is not used: %v", path, err)
+		}
+	})
+}
+
+func parseBundledFonts() {
+	parsedFonts = make(map[string]*sfnt.Font, len(bundledFonts))
+	for name, data := range bundledFonts {
+		f, err := opentype.Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("bundled font %q is broken: %v", name, err))
+		}
+		parsedFonts[name] = f
+	}
+}
+
+// fontChain is the font and the fallbacks for glyphs it doesn't have
+func fontChain(name string) []*sfnt.Font {
+	loadFonts()
+	chain := []*sfnt.Font{parsedFonts[name]}
+	if emojiFont != nil {
+		chain = append(chain, emojiFont)
+	}
+	return chain
+}
+
+// textRun is a part of a line drawn with one face
+type textRun struct {
+	face font.Face
+	text string
+}
+
+// textFaces is the font chain at one size
+type textFaces struct {
+	fonts []*sfnt.Font
+	faces []font.Face
+	buf   sfnt.Buffer
+}
+
+func newTextFaces(fonts []*sfnt.Font, size float64) (*textFaces, error) {
+	t := &textFaces{fonts: fonts}
+	for _, f := range fonts {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{
+			Size:    size,
+			DPI:     72,
+			Hinting: font.HintingNone,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create font face: %w", err)
+		}
+		t.faces = append(t.faces, face)
+	}
+	return t, nil
+}
+
+// faceFor is the first face that has the glyph, -1 when none has it
+func (t *textFaces) faceFor(r rune) int {
+	for i, f := range t.fonts {
+		if index, err := f.GlyphIndex(&t.buf, r); err == nil && index != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// runs splits the text by face, glyphs no font has are dropped
+func (t *textFaces) runs(s string) []textRun {
+	var runs []textRun
+	current := -1
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			runs = append(runs, textRun{t.faces[current], text.String()})
+			text.Reset()
+		}
+	}
+
+	for _, r := range s {
+		i := t.faceFor(r)
+		if i < 0 {
+			continue
+		}
+		if i != current {
+			flush()
+			current = i
+		}
+		text.WriteRune(r)
+	}
+	flush()
+	return runs
+}
+
+func (t *textFaces) measure(s string) fixed.Int26_6 {
+	var width fixed.Int26_6
+	for _, run := range t.runs(s) {
+		width += font.MeasureString(run.face, run.text)
+	}
+	return width
+}
+
+// wrap breaks the text into lines no wider than width, words that don't
+// fit on their own are split between letters
+func (t *textFaces) wrap(text string, width fixed.Int26_6) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			wordWidth := t.measure(word)
+			if wordWidth == 0 {
+				// none of the fonts can draw it
+				continue
+			}
+			if wordWidth > width {
+				if line != "" {
+					lines = append(lines, line)
+				}
+				pieces := t.splitWord(word, width)
+				lines = append(lines, pieces[:len(pieces)-1]...)
+				line = pieces[len(pieces)-1]
+				continue
+			}
+
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && t.measure(candidate) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (t *textFaces) splitWord(word string, width fixed.Int26_6) []string {
+	var pieces []string
+	piece := ""
+	for _, r := range word {
+		if piece != "" && t.measure(piece+string(r)) > width {
+			pieces = append(pieces, piece)
+			piece = ""
+		}
+		piece += string(r)
+	}
+	return append(pieces, piece)
+}
+
+// renderText wraps the text and picks the biggest size up to maxSize that
+// fits the width x height box, the letters are stroked like meme captions
+func renderText(
+	text string,
+	style *emote.TextStyle,
+	width, height int,
+	maxSize float64,
+	align int,
+	profile Profile,
+) (*image.NRGBA, error) {
+	if style == nil {
+		style = &emote.TextStyle{}
+	}
+	fonts := fontChain(style.Font)
+	minSize := minTextSize * float64(profile.Size) / outlineReference
+
+	for size := maxSize; size >= minSize; size *= textSizeStep {
+		stroke := max(1, int(math.Round(size*textStrokeShare)))
+		faces, err := newTextFaces(fonts, size)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := faces.wrap(text, fixed.I(width-2*stroke))
+		if strings.Join(lines, "") == "" {
+			// the glyphs don't depend on the size, shrinking won't help
+			return nil, fmt.Errorf("text %q has nothing the fonts can draw", text)
+		}
+		metrics := faces.faces[0].Metrics()
+		lineHeight := float64(metrics.Height) / 64 * textLineSpacing
+		blockHeight := lineHeight*float64(len(lines)-1) +
+			float64(metrics.Ascent+metrics.Descent)/64
+		if blockHeight > float64(height-2*stroke) {
+			continue
+		}
+
+		top := float64(stroke)
+		switch align {
+		case alignCenter:
+			top = (float64(height) - blockHeight) / 2
+		case alignBottom:
+			top = float64(height-stroke) - blockHeight
+		}
+		return drawText(faces, lines, style, width, height, stroke,
+			top+float64(metrics.Ascent)/64, lineHeight), nil
+	}
+	return nil, fmt.Errorf("text %q doesn't fit the sticker", text)
+}
+
+// drawText draws the lines centered from the baseline of the first one
+func drawText(
+	faces *textFaces,
+	lines []string,
+	style *emote.TextStyle,
+	width, height, stroke int,
+	baseline, lineHeight float64,
+) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	fill := image.NewUniform(style.Fill())
+	for i, line := range lines {
+		x := (fixed.I(width) - faces.measure(line)) / 2
+		y := fixed.Int26_6((baseline + lineHeight*float64(i)) * 64)
+		dot := fixed.Point26_6{X: x, Y: y}
+		for _, run := range faces.runs(line) {
+			d := font.Drawer{Dst: img, Src: fill, Face: run.face, Dot: dot}
+			d.DrawString(run.text)
+			dot = d.Dot
+		}
+	}
+
+	layer := strokeLayer(img, stroke, style.Stroke())
+	draw.Draw(layer, layer.Bounds(), img, image.Point{}, draw.Over)
+	return layer
+}
+
+// renderTextSticker draws a text-only sticker as big as it fits, fitImage
+// trims and scales it like any other emote
+func renderTextSticker(text string, opts emote.Options, profile Profile) (*image.NRGBA, error) {
+	size := profile.Size
+	return renderText(
+		text, opts.TextStyle, size, size, float64(size), alignCenter, profile,
+	)
+}
+
+// captionBand is the caption drawn on a strip as wide as the art, it goes
+// at the top or the bottom of a width x height emote
+func captionBand(c *emote.Caption, width, height int, profile Profile) (*image.NRGBA, error) {
+	band := max(1, int(float64(height)*captionShare))
+	align := alignTop
+	if c.Position == emote.CaptionBottom {
+		align = alignBottom
+	}
+	return renderText(c.Text, &c.TextStyle, width, band, float64(band), align, profile)
+}
+
+// applyCaption draws the caption over the art
+func applyCaption(img *image.NRGBA, c *emote.Caption, profile Profile) (*image.NRGBA, error) {
+	bounds := img.Bounds()
+	band, err := captionBand(c, bounds.Dx(), bounds.Dy(), profile)
+	if err != nil {
+		return nil, err
+	}
+
+	y := bounds.Min.Y
+	if c.Position == emote.CaptionBottom {
+		y = bounds.Max.Y - band.Bounds().Dy()
+	}
+	rect := band.Bounds().Add(image.Pt(bounds.Min.X, y))
+	draw.Draw(img, rect, band, image.Point{}, draw.Over)
+	return img, nil
+}
+
+// writeCaption saves the caption band of a width x height video as png
+func writeCaption(path string, c *emote.Caption, width, height int, profile Profile) error {
+	band, err := captionBand(c, width, height, profile)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, band); err != nil {
+		return fmt.Errorf("failed to encode caption: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write caption: %w", err)
+	}
+	return nil
+}
+
+// captionFilter overlays the caption saved at path on an ffmpeg stream,
+// movie reads the png as a single frame that overlay repeats
+func captionFilter(c *emote.Caption, path string) string {
+	y := "0"
+	if c.Position == emote.CaptionBottom {
+		y = "H-h"
+	}
+	return fmt.Sprintf(
+		"format=rgba[art];movie=%s,format=rgba[caption];"+
+			"[art][caption]overlay=x=(W-w)/2:y=%s:format=auto",
+		escapeFilterValue(path), y,
+	)
+}
+
+var (
+	// special in an option value, like the : between options
+	optionEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+	// special in the graph, like the , between filters
+	graphEscaper = strings.NewReplacer(
+		`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`,
+	)
+)
+
+// escapeFilterValue escapes an option value for both levels of the
+// filtergraph, temp dirs can contain anything TMPDIR is set to
+func escapeFilterValue(value string) string {
+	return graphEscaper.Replace(optionEscaper.Replace(value))
+}
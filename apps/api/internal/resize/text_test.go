@@ -0,0 +1,53 @@
+package resize
+
+import (
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+func TestRenderText(t *testing.T) {
+	// only the bundled fonts, there is no emoji font without the config
+	fontsOnce.Do(parseBundledFonts)
+
+	tests := []struct {
+		text string
+		ok   bool
+	}{
+		{"hello", true},
+		{"hello \U0001F600", true},
+		{"\U0001F600", false},
+		{"\U0001F600 \U0001F525", false},
+	}
+
+	for _, tt := range tests {
+		img, err := renderTextSticker(tt.text, emote.Options{}, StickerProfile)
+		if got := err == nil; got != tt.ok {
+			t.Errorf("%q: err = %v, want ok %v", tt.text, err, tt.ok)
+			continue
+		}
+		if tt.ok && contentBounds(img).Empty() {
+			t.Errorf("%q: nothing was drawn", tt.text)
+		}
+	}
+}
+
+func TestEscapeFilterValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "/tmp/gifconv123/caption.png", "/tmp/gifconv123/caption.png"},
+		{"colon", "C:/tmp/caption.png", `C\\:/tmp/caption.png`},
+		{"quote", "/tmp/it's/caption.png", `/tmp/it\\\'s/caption.png`},
+		{"graph", "/tmp/a,b;[c]/caption.png", `/tmp/a\,b\;\[c\]/caption.png`},
+		{"backslash", `/tmp/a\b`, `/tmp/a\\\\b`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeFilterValue(tt.value); got != tt.want {
+			t.Errorf("%s: escapeFilterValue = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
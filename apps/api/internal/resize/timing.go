@@ -0,0 +1,91 @@
+package resize
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+// timeline is the animation after the timing options
+type timeline struct {
+	filter   string
+	duration float64
+	// frame rate of the shortest frame
+	fps float64
+}
+
+// timingFilter trims, reverses and speeds up the animation. Frame
+// timestamps are kept as they are, frames are only dropped when the fps
+// gets too high. Cut animations are trimmed to what fits the sticker
+// before reverse, which buffers every frame it gets
+func timingFilter(opts emote.Options, info *videoInfo) (timeline, error) {
+	var filters []string
+
+	// single frames and some containers have no duration
+	if info.Duration <= 0 {
+		info.Duration = maxDuration
+	}
+
+	start := opts.TrimStart
+	end := info.Duration
+	if opts.TrimEnd > 0 && opts.TrimEnd < end {
+		end = opts.TrimEnd
+	}
+	if start >= end {
+		return timeline{}, fmt.Errorf(
+			"trim starts at %.2fs but the animation is %.2fs long",
+			start, info.Duration,
+		)
+	}
+	// boomerang plays the clip twice
+	plays := 1.0
+	if opts.Boomerang {
+		plays = 2
+	}
+	if opts.Timing == emote.TimingCut && (end-start)*plays > maxDuration {
+		end = start + maxDuration/plays
+	}
+	if start > 0 || end < info.Duration {
+		filters = append(filters, fmt.Sprintf(
+			"trim=start=%.3f:end=%.3f,setpts=PTS-STARTPTS", start, end,
+		))
+	}
+	duration := (end - start) * plays
+
+	// the speed up raises the frame rate, so the source is capped to
+	// what ends up at maxFPS
+	factor := 1.0
+	if opts.Timing == emote.TimingSpeed && duration > maxDuration {
+		factor = maxDuration / duration
+	}
+	fps := info.FPS
+	if limit := maxFPS * factor; fps == 0 || fps > limit {
+		filters = append(filters, fmt.Sprintf("fps=%.4g", limit))
+		fps = limit
+	}
+
+	if opts.Reverse {
+		filters = append(filters, "reverse")
+	}
+	if opts.Boomerang {
+		// the turnaround frame isn't shown twice
+		filters = append(filters,
+			"split[forward][backward];"+
+				"[backward]reverse,trim=start_frame=1,setpts=PTS-STARTPTS[back];"+
+				"[forward][back]concat=n=2:v=1:a=0",
+		)
+	}
+
+	if factor < 1 {
+		filters = append(filters, fmt.Sprintf("setpts=PTS*%.5f", factor))
+		fps /= factor
+		duration = maxDuration
+	}
+
+	return timeline{
+		filter:   strings.Join(filters, ","),
+		duration: capDuration(duration),
+		fps:      fps,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package resize
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+func TestTimingFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     emote.Options
+		info     videoInfo
+		filter   string
+		duration float64
+		fps      float64
+	}{
+		{
+			name:     "short clip is left alone",
+			info:     videoInfo{FPS: 25, Duration: 2},
+			duration: 2,
+			fps:      25,
+		},
+		{
+			name:     "long clip is cut",
+			info:     videoInfo{FPS: 25, Duration: 10},
+			filter:   "trim=start=0.000:end=3.000,setpts=PTS-STARTPTS",
+			duration: maxDuration,
+			fps:      25,
+		},
+		{
+			name:     "missing duration",
+			info:     videoInfo{FPS: 10},
+			duration: maxDuration,
+			fps:      10,
+		},
+		{
+			name:     "high fps is dropped",
+			info:     videoInfo{FPS: 60, Duration: 1},
+			filter:   "fps=30",
+			duration: 1,
+			fps:      maxFPS,
+		},
+		{
+			name:     "unknown fps",
+			info:     videoInfo{Duration: 1},
+			filter:   "fps=30",
+			duration: 1,
+			fps:      maxFPS,
+		},
+		{
+			name:     "trim",
+			opts:     emote.Options{TrimStart: 1, TrimEnd: 2.5},
+			info:     videoInfo{FPS: 20, Duration: 5},
+			filter:   "trim=start=1.000:end=2.500,setpts=PTS-STARTPTS",
+			duration: 1.5,
+			fps:      20,
+		},
+		{
+			name:     "trim end past the duration",
+			opts:     emote.Options{TrimEnd: 8},
+			info:     videoInfo{FPS: 20, Duration: 2},
+			duration: 2,
+			fps:      20,
+		},
+		{
+			name:     "reverse",
+			opts:     emote.Options{Reverse: true},
+			info:     videoInfo{FPS: 20, Duration: 2},
+			filter:   "reverse",
+			duration: 2,
+			fps:      20,
+		},
+		{
+			name: "boomerang doubles the length",
+			opts: emote.Options{Boomerang: true},
+			info: videoInfo{FPS: 20, Duration: 1},
+			filter: "split[forward][backward];" +
+				"[backward]reverse,trim=start_frame=1,setpts=PTS-STARTPTS[back];" +
+				"[forward][back]concat=n=2:v=1:a=0",
+			duration: 2,
+			fps:      20,
+		},
+		{
+			name:     "speed up",
+			opts:     emote.Options{Timing: emote.TimingSpeed},
+			info:     videoInfo{FPS: 10, Duration: 6},
+			filter:   "setpts=PTS*0.50000",
+			duration: maxDuration,
+			fps:      20,
+		},
+		{
+			name:     "speed up past the fps limit",
+			opts:     emote.Options{Timing: emote.TimingSpeed},
+			info:     videoInfo{FPS: 25, Duration: 6},
+			filter:   "fps=15,setpts=PTS*0.50000",
+			duration: maxDuration,
+			fps:      maxFPS,
+		},
+		{
+			name:     "long clip is cut before reverse",
+			opts:     emote.Options{Reverse: true},
+			info:     videoInfo{FPS: 25, Duration: 10},
+			filter:   "trim=start=0.000:end=3.000,setpts=PTS-STARTPTS,reverse",
+			duration: maxDuration,
+			fps:      25,
+		},
+		{
+			name:     "trim start counts from the cut",
+			opts:     emote.Options{Reverse: true, TrimStart: 4},
+			info:     videoInfo{FPS: 25, Duration: 10},
+			filter:   "trim=start=4.000:end=7.000,setpts=PTS-STARTPTS,reverse",
+			duration: maxDuration,
+			fps:      25,
+		},
+		{
+			name: "long boomerang keeps the way back",
+			opts: emote.Options{Boomerang: true},
+			info: videoInfo{FPS: 25, Duration: 10},
+			filter: "trim=start=0.000:end=1.500,setpts=PTS-STARTPTS," +
+				"split[forward][backward];" +
+				"[backward]reverse,trim=start_frame=1,setpts=PTS-STARTPTS[back];" +
+				"[forward][back]concat=n=2:v=1:a=0",
+			duration: maxDuration,
+			fps:      25,
+		},
+		{
+			name:     "fps is capped before reverse",
+			opts:     emote.Options{Reverse: true},
+			info:     videoInfo{FPS: 100, Duration: 10},
+			filter:   "trim=start=0.000:end=3.000,setpts=PTS-STARTPTS,fps=30,reverse",
+			duration: maxDuration,
+			fps:      maxFPS,
+		},
+		{
+			name:     "speed up keeps the whole clip",
+			opts:     emote.Options{Timing: emote.TimingSpeed, Reverse: true},
+			info:     videoInfo{FPS: 10, Duration: 10},
+			filter:   "fps=9,reverse,setpts=PTS*0.30000",
+			duration: maxDuration,
+			fps:      maxFPS,
+		},
+		{
+			name:     "short clip isn't sped up",
+			opts:     emote.Options{Timing: emote.TimingSpeed},
+			info:     videoInfo{FPS: 10, Duration: 2},
+			duration: 2,
+			fps:      10,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := timingFilter(tt.opts, &tt.info)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.filter != tt.filter {
+			t.Errorf("%s: filter = %q, want %q", tt.name, got.filter, tt.filter)
+		}
+		if math.Abs(got.duration-tt.duration) > 1e-9 {
+			t.Errorf("%s: duration = %v, want %v", tt.name, got.duration, tt.duration)
+		}
+		if math.Abs(got.fps-tt.fps) > 1e-9 {
+			t.Errorf("%s: fps = %v, want %v", tt.name, got.fps, tt.fps)
+		}
+	}
+}
+
+func TestTimingFilterTrimPastEnd(t *testing.T) {
+	opts := emote.Options{TrimStart: 3}
+	if _, err := timingFilter(opts, &videoInfo{FPS: 10, Duration: 2}); err == nil {
+		t.Error("trim starting after the end was accepted")
+	}
+}
@@ -0,0 +1,136 @@
+package resize
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+	"github.com/disintegration/imaging"
+)
+
+// applyTransforms edits a static emote before it's fitted
+func applyTransforms(img image.Image, transforms []emote.Transform) (image.Image, error) {
+	for _, t := range transforms {
+		switch t.Type {
+		case emote.TransformRotate:
+			// imaging rotates counter-clockwise
+			switch t.Degrees {
+			case 90:
+				img = imaging.Rotate270(img)
+			case 180:
+				img = imaging.Rotate180(img)
+			case 270:
+				img = imaging.Rotate90(img)
+			}
+		case emote.TransformMirror:
+			if t.Axis == emote.MirrorVertical {
+				img = imaging.FlipV(img)
+			} else {
+				img = imaging.FlipH(img)
+			}
+		case emote.TransformCrop:
+			bounds := img.Bounds()
+			rect, err := cropRect(t.Rect, bounds.Dx(), bounds.Dy())
+			if err != nil {
+				return nil, err
+			}
+			img = imaging.Crop(img, rect.Add(bounds.Min))
+		case emote.TransformGrayscale:
+			img = imaging.Grayscale(img)
+		case emote.TransformHue:
+			img = shiftHue(img, t.Hue)
+		case emote.TransformAdjust:
+			img = imaging.AdjustContrast(
+				imaging.AdjustBrightness(img, t.Brightness), t.Contrast,
+			)
+		}
+	}
+	return img, nil
+}
+
+// shiftHue rotates the chroma like the ffmpeg hue filter does, so static
+// and animated emotes get the same colors
+func shiftHue(img image.Image, degrees float64) *image.NRGBA {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	chroma := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(255, math.Round(v+128))))
+	}
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		y, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+		u, v := float64(cb)-128, float64(cr)-128
+		r, g, b := color.YCbCrToRGB(y, chroma(u*cos-v*sin), chroma(u*sin+v*cos))
+		return color.NRGBA{r, g, b, c.A}
+	})
+}
+
+// frameGeometry is the frame size and the visible content of a video
+// as it goes through the transforms
+type frameGeometry struct {
+	width, height int
+	content       image.Rectangle
+}
+
+// transformFilter is the ffmpeg equivalent of applyTransforms, the
+// content found on the original frames is moved along
+func transformFilter(
+	transforms []emote.Transform,
+	geo frameGeometry,
+) (string, frameGeometry, error) {
+	var parts []string
+	for i, t := range transforms {
+		w, h := geo.width, geo.height
+		c := geo.content
+		label := fmt.Sprintf("tf%d", i)
+		switch t.Type {
+		case emote.TransformRotate:
+			switch t.Degrees {
+			case 90:
+				parts = append(parts, "transpose=clock")
+				geo.content = image.Rect(h-c.Max.Y, c.Min.X, h-c.Min.Y, c.Max.X)
+			case 180:
+				parts = append(parts, "hflip,vflip")
+				geo.content = image.Rect(w-c.Max.X, h-c.Max.Y, w-c.Min.X, h-c.Min.Y)
+			case 270:
+				parts = append(parts, "transpose=cclock")
+				geo.content = image.Rect(c.Min.Y, w-c.Max.X, c.Max.Y, w-c.Min.X)
+			}
+			if t.Degrees != 180 {
+				geo.width, geo.height = h, w
+			}
+		case emote.TransformMirror:
+			if t.Axis == emote.MirrorVertical {
+				parts = append(parts, "vflip")
+				geo.content = image.Rect(c.Min.X, h-c.Max.Y, c.Max.X, h-c.Min.Y)
+			} else {
+				parts = append(parts, "hflip")
+				geo.content = image.Rect(w-c.Max.X, c.Min.Y, w-c.Min.X, c.Max.Y)
+			}
+		case emote.TransformCrop:
+			rect, err := cropRect(t.Rect, w, h)
+			if err != nil {
+				return "", geo, err
+			}
+			parts = append(parts, fmt.Sprintf(
+				"crop=%d:%d:%d:%d", rect.Dx(), rect.Dy(), rect.Min.X, rect.Min.Y,
+			))
+			geo.width, geo.height = rect.Dx(), rect.Dy()
+			geo.content = c.Intersect(rect).Sub(rect.Min)
+		case emote.TransformGrayscale:
+			parts = append(parts, colorFilter(label, "hue=s=0"))
+		case emote.TransformHue:
+			parts = append(parts, colorFilter(label, fmt.Sprintf("hue=h=%g", t.Hue)))
+		case emote.TransformAdjust:
+			parts = append(parts, colorFilter(label, fmt.Sprintf(
+				"eq=brightness=%g:contrast=%g", t.Brightness/100, 1+t.Contrast/100,
+			)))
+		}
+		if c.Empty() {
+			// nothing is known about the content, it stays unknown
+			geo.content = image.Rectangle{}
+		}
+	}
+	return strings.Join(parts, ","), geo, nil
+}
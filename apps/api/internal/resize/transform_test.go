@@ -0,0 +1,100 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+// the content of a video is moved the way applyTransforms moves the
+// pixels of a static emote
+func TestTransformFilterContent(t *testing.T) {
+	rotate := func(degrees int) emote.Transform {
+		return emote.Transform{Type: emote.TransformRotate, Degrees: degrees}
+	}
+	crop := emote.Transform{
+		Type: emote.TransformCrop,
+		Rect: &emote.CropRect{X: 10, Y: 5, Width: 40, Height: 30},
+	}
+
+	tests := []struct {
+		name       string
+		transforms []emote.Transform
+		filter     string
+	}{
+		{"rotate 90", []emote.Transform{rotate(90)}, "transpose=clock"},
+		{"rotate 180", []emote.Transform{rotate(180)}, "hflip,vflip"},
+		{"rotate 270", []emote.Transform{rotate(270)}, "transpose=cclock"},
+		{"mirror", []emote.Transform{{Type: emote.TransformMirror}}, "hflip"},
+		{
+			"mirror vertically",
+			[]emote.Transform{{Type: emote.TransformMirror, Axis: emote.MirrorVertical}},
+			"vflip",
+		},
+		{"crop", []emote.Transform{crop}, "crop=40:30:10:5"},
+		{"rotate then crop", []emote.Transform{rotate(90), crop}, "transpose=clock,crop=40:30:10:5"},
+		{"crop then rotate", []emote.Transform{crop, rotate(270)}, "crop=40:30:10:5,transpose=cclock"},
+	}
+
+	const width, height = 80, 60
+	content := image.Rect(12, 8, 30, 50)
+	for _, tt := range tests {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(img, content, image.NewUniform(color.NRGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+		transformed, err := applyTransforms(img, tt.transforms)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		filter, geo, err := transformFilter(tt.transforms, frameGeometry{
+			width: width, height: height, content: content,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if filter != tt.filter {
+			t.Errorf("%s: filter = %q, want %q", tt.name, filter, tt.filter)
+		}
+
+		bounds := transformed.Bounds()
+		if geo.width != bounds.Dx() || geo.height != bounds.Dy() {
+			t.Errorf(
+				"%s: frame %dx%d, want %dx%d",
+				tt.name, geo.width, geo.height, bounds.Dx(), bounds.Dy(),
+			)
+		}
+		if want := contentBounds(transformed).Sub(bounds.Min); geo.content != want {
+			t.Errorf("%s: content = %v, want %v", tt.name, geo.content, want)
+		}
+	}
+}
+
+func TestTransformFilterUnknownContent(t *testing.T) {
+	transforms := []emote.Transform{
+		{Type: emote.TransformRotate, Degrees: 90},
+		{Type: emote.TransformMirror},
+	}
+	_, geo, err := transformFilter(transforms, frameGeometry{width: 80, height: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !geo.content.Empty() {
+		t.Errorf("content = %v, want it to stay unknown", geo.content)
+	}
+	if geo.width != 60 || geo.height != 80 {
+		t.Errorf("frame %dx%d, want 60x80", geo.width, geo.height)
+	}
+}
+
+func TestTransformFilterCropOutside(t *testing.T) {
+	transforms := []emote.Transform{{
+		Type: emote.TransformCrop,
+		Rect: &emote.CropRect{X: 100, Y: 0, Width: 10, Height: 10},
+	}}
+	if _, _, err := transformFilter(transforms, frameGeometry{width: 80, height: 60}); err == nil {
+		t.Error("crop outside of the frame succeeded")
+	}
+}
@@ -0,0 +1,140 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+)
+
+// alpha at or below this is treated as transparent, some encoders leave
+// barely visible noise around the art
+const trimAlphaThreshold = 8
+
+var bboxPattern = regexp.MustCompile(`x1:(\d+) x2:(\d+) y1:(\d+) y2:(\d+)`)
+
+// contentBounds is the smallest rectangle with all visible pixels,
+// it's empty for fully transparent images
+func contentBounds(img image.Image) image.Rectangle {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img.Bounds()
+	}
+
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return scanAlpha(img.Rect, func(x, y int) uint8 {
+			return img.Pix[img.PixOffset(x, y)+3]
+		})
+	case *image.Paletted:
+		alpha := make([]uint8, len(img.Palette))
+		for i, c := range img.Palette {
+			_, _, _, a := c.RGBA()
+			alpha[i] = uint8(a >> 8)
+		}
+		return scanAlpha(img.Rect, func(x, y int) uint8 {
+			return alpha[img.Pix[img.PixOffset(x, y)]]
+		})
+	default:
+		return scanAlpha(img.Bounds(), func(x, y int) uint8 {
+			_, _, _, a := img.At(x, y).RGBA()
+			return uint8(a >> 8)
+		})
+	}
+}
+
+func scanAlpha(bounds image.Rectangle, alphaAt func(x, y int) uint8) image.Rectangle {
+	minX, minY := bounds.Max.X, bounds.Max.Y
+	maxX, maxY := bounds.Min.X-1, bounds.Min.Y-1
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if alphaAt(x, y) <= trimAlphaThreshold {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+			if y < minY {
+				minY = y
+			}
+			maxY = y
+		}
+	}
+
+	if maxX < minX {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX+1, maxY+1)
+}
+
+// animatedContentBounds is the union of the visible pixels of all frames,
+// trimming is best effort so an empty rectangle is returned on errors
+func animatedContentBounds(
+	ctx context.Context,
+	format string,
+	source *ffmpegInput,
+	background string,
+) image.Rectangle {
+	// the removed background only shows up in the filtered frames
+	if background != "" {
+		format = formatUnknown
+	}
+
+	switch {
+	case format == formatWebP:
+		return source.content
+	case format == formatGIF && source.firstFrame != nil:
+		return source.content
+	case format == formatMP4:
+		// no alpha channel
+		return image.Rectangle{}
+	}
+
+	content, err := videoContentBounds(ctx, source, background)
+	if err != nil {
+		return image.Rectangle{}
+	}
+	return content
+}
+
+// videoContentBounds runs the ffmpeg bbox filter on the alpha plane,
+// background is the filter that removes it if any
+func videoContentBounds(
+	ctx context.Context,
+	source *ffmpegInput,
+	background string,
+) (image.Rectangle, error) {
+	filter := fmt.Sprintf("alphaextract,bbox=min_val=%d", trimAlphaThreshold+1)
+	if background != "" {
+		filter = background + "," + filter
+	}
+
+	args := append([]string{}, source.args...)
+	args = append(args,
+		"-t", fmt.Sprintf("%.2f", maxSourceDuration),
+		"-vf", filter,
+		"-f", "null",
+		"-",
+	)
+
+	var stderr bytes.Buffer
+	p := ffmpeg(args...)
+	p.stderr = &stderr
+	if err := p.run(ctx); err != nil {
+		return image.Rectangle{}, fmt.Errorf("bbox failed: %w", err)
+	}
+
+	content := image.Rectangle{}
+	for _, match := range bboxPattern.FindAllStringSubmatch(stderr.String(), -1) {
+		x1, _ := strconv.Atoi(match[1])
+		x2, _ := strconv.Atoi(match[2])
+		y1, _ := strconv.Atoi(match[3])
+		y2, _ := strconv.Atoi(match[4])
+		content = content.Union(image.Rect(x1, y1, x2+1, y2+1))
+	}
+	return content, nil
+}
@@ -0,0 +1,83 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/emote"
+)
+
+const (
+	maxStaticSize  = 512 * 1024 // 512 KB
+	minWebPQuality = 5
+	maxWebPQuality = 95
+)
+
+// encodeStatic picks the output format for a fitted png
+func encodeStatic(ctx context.Context, pngData []byte, format string) ([]byte, error) {
+	switch format {
+	case emote.StaticFormatPNG:
+		if len(pngData) > maxStaticSize {
+			return nil, fmt.Errorf(
+				"png is %d bytes, the limit is %d", len(pngData), maxStaticSize,
+			)
+		}
+		return pngData, nil
+	case emote.StaticFormatWebP:
+		return fitWebP(ctx, pngData)
+	default:
+		if len(pngData) <= maxStaticSize {
+			return pngData, nil
+		}
+		return fitWebP(ctx, pngData)
+	}
+}
+
+// fitWebP encodes the highest quality webp that fits the size limit
+func fitWebP(ctx context.Context, pngData []byte) ([]byte, error) {
+	return searchQuality(func(quality int) ([]byte, error) {
+		return encodeWebP(ctx, pngData, quality)
+	})
+}
+
+// searchQuality binary searches the quality range for the best output
+// of encode that fits maxStaticSize
+func searchQuality(encode func(quality int) ([]byte, error)) ([]byte, error) {
+	var best []byte
+	low, high := minWebPQuality, maxWebPQuality
+	for low <= high {
+		quality := (low + high) / 2
+		output, err := encode(quality)
+		if err != nil {
+			return nil, fmt.Errorf("webp encoding failed: %w", err)
+		}
+
+		if len(output) <= maxStaticSize {
+			best = output
+			low = quality + 1
+		} else {
+			high = quality - 1
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("webp exceeds %d bytes at any quality", maxStaticSize)
+	}
+	return best, nil
+}
+
+func encodeWebP(ctx context.Context, pngData []byte, quality int) ([]byte, error) {
+	p := ffmpeg(
+		"-f", "png_pipe",
+		"-i", "pipe:0",
+		"-c:v", "libwebp",
+		"-pix_fmt", "yuva420p", // keeps transparency
+		"-quality", fmt.Sprintf("%d", quality),
+		"-compression_level", "6",
+		"-f", "webp",
+		"pipe:1",
+	)
+	p.stdin = bytes.NewReader(pngData)
+	return p.output(ctx)
+}
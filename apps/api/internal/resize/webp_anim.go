@@ -0,0 +1,229 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+const (
+	webpAlphaFlag     = 0x10
+	anmfNoBlend       = 0x02
+	anmfDispose       = 0x01
+	anmfHeaderSize    = 16
+	vp8xPayloadSize   = 10
+	minWebPFrameDelay = 10 * time.Millisecond
+	// browsers play frames with tiny delays at 10 fps
+	defaultWebPFrameDelay = 100 * time.Millisecond
+)
+
+type webpChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+type animationFrame struct {
+	img   *image.NRGBA
+	delay time.Duration
+}
+
+// decodeAnimatedWebP composites the frames of an animated webp onto its
+// canvas and passes them to emit, x/image/webp only reads the first frame.
+// Decoding stops once maxLength of animation has been read
+func decodeAnimatedWebP(
+	ctx context.Context,
+	data []byte,
+	maxLength time.Duration,
+	emit func(animationFrame) error,
+) error {
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 || chunks[0].fourCC != "VP8X" ||
+		len(chunks[0].payload) < vp8xPayloadSize {
+		return fmt.Errorf("webp has no extended header")
+	}
+
+	// the canvas and every snapshot are allocated at the declared size,
+	// so it is checked before anything is decoded
+	header := chunks[0].payload
+	width, height := int(uint24(header[4:]))+1, int(uint24(header[7:]))+1
+	if width*height > maxSourcePixels {
+		return fmt.Errorf("webp canvas %dx%d is too big", width, height)
+	}
+	var frames []webpChunk
+	var length time.Duration
+	for _, chunk := range chunks[1:] {
+		if chunk.fourCC != "ANMF" || length >= maxLength {
+			continue
+		}
+		frames = append(frames, chunk)
+		length += anmfDelay(chunk.payload)
+	}
+	if len(frames) > maxSourceFrames {
+		return fmt.Errorf(
+			"webp has %d frames, at most %d are allowed", len(frames), maxSourceFrames,
+		)
+	}
+	// every frame is a full canvas snapshot, tiny frames cost as much
+	if pixels := int64(len(frames)) * int64(width*height); pixels > maxDecodedPixels {
+		return fmt.Errorf(
+			"webp has %d frames of %dx%d, that is too much to decode",
+			len(frames), width, height,
+		)
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for i, chunk := range frames {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		frame, err := decodeWebPFrame(canvas, chunk.payload)
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		if err := emit(frame); err != nil {
+			return err
+		}
+	}
+
+	if len(frames) == 0 {
+		return fmt.Errorf("animated webp has no frames")
+	}
+	return nil
+}
+
+// anmfDelay is how long the frame is shown
+func anmfDelay(payload []byte) time.Duration {
+	if len(payload) < anmfHeaderSize {
+		return defaultWebPFrameDelay
+	}
+	delay := time.Duration(uint24(payload[12:])) * time.Millisecond
+	if delay < minWebPFrameDelay {
+		return defaultWebPFrameDelay
+	}
+	return delay
+}
+
+// decodeWebPFrame draws an ANMF frame onto the canvas and returns a
+// snapshot, the canvas is left ready for the next frame
+func decodeWebPFrame(canvas *image.NRGBA, payload []byte) (animationFrame, error) {
+	if len(payload) < anmfHeaderSize {
+		return animationFrame{}, fmt.Errorf("truncated frame header")
+	}
+
+	x := int(uint24(payload[0:])) * 2
+	y := int(uint24(payload[3:])) * 2
+	width := int(uint24(payload[6:])) + 1
+	height := int(uint24(payload[9:])) + 1
+	flags := payload[15]
+
+	standalone := standaloneWebP(payload[anmfHeaderSize:], width, height)
+	// the bitstream has its own size, it can differ from the frame header
+	frameConfig, err := webp.DecodeConfig(bytes.NewReader(standalone))
+	if err != nil {
+		return animationFrame{}, err
+	}
+	canvasSize := canvas.Bounds().Size()
+	if frameConfig.Width*frameConfig.Height > canvasSize.X*canvasSize.Y {
+		return animationFrame{}, fmt.Errorf("frame is bigger than the canvas")
+	}
+	img, err := webp.Decode(bytes.NewReader(standalone))
+	if err != nil {
+		return animationFrame{}, err
+	}
+
+	rect := image.Rect(x, y, x+width, y+height).Intersect(canvas.Bounds())
+	op := draw.Over
+	if flags&anmfNoBlend != 0 {
+		op = draw.Src
+	}
+	draw.Draw(canvas, rect, img, img.Bounds().Min, op)
+
+	snapshot := image.NewNRGBA(canvas.Bounds())
+	copy(snapshot.Pix, canvas.Pix)
+
+	if flags&anmfDispose != 0 {
+		draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+	}
+
+	return animationFrame{snapshot, anmfDelay(payload)}, nil
+}
+
+// standaloneWebP wraps the bitstream chunks of a frame into a file
+// x/image/webp can decode, lossy frames with alpha need a VP8X header
+func standaloneWebP(frameData []byte, width, height int) []byte {
+	chunks, _ := readWebPChunks(append([]byte("RIFF\x00\x00\x00\x00WEBP"), frameData...))
+
+	var body bytes.Buffer
+	for _, chunk := range chunks {
+		if chunk.fourCC != "ALPH" {
+			continue
+		}
+		header := make([]byte, vp8xPayloadSize)
+		header[0] = webpAlphaFlag
+		putUint24(header[4:], uint32(width-1))
+		putUint24(header[7:], uint32(height-1))
+		writeWebPChunk(&body, "VP8X", header)
+		break
+	}
+	for _, chunk := range chunks {
+		switch chunk.fourCC {
+		case "ALPH", "VP8 ", "VP8L":
+			writeWebPChunk(&body, chunk.fourCC, chunk.payload)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()+4))
+	out.WriteString("WEBP")
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+// readWebPChunks splits a RIFF file into chunks, payloads are padded
+// to an even length
+func readWebPChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("webp is too short")
+	}
+
+	var chunks []webpChunk
+	rest := data[12:]
+	for len(rest) >= 8 {
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		if size > len(rest)-8 {
+			return nil, fmt.Errorf("truncated %q chunk", rest[:4])
+		}
+		chunks = append(chunks, webpChunk{string(rest[:4]), rest[8 : 8+size]})
+		rest = rest[min(8+size+size%2, len(rest)):]
+	}
+	return chunks, nil
+}
+
+func writeWebPChunk(buf *bytes.Buffer, fourCC string, payload []byte) {
+	buf.WriteString(fourCC)
+	binary.Write(buf, binary.LittleEndian, uint32(len(payload)))
+	buf.Write(payload)
+	if len(payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
@@ -0,0 +1,93 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// animatedWebP is a VP8X header for a width x height canvas followed by
+// empty ANMF chunks, enough to hit the limits before any decoding
+func animatedWebP(width, height, frames int) []byte {
+	var body bytes.Buffer
+	header := make([]byte, vp8xPayloadSize)
+	header[0] = 0x02
+	putUint24(header[4:], uint32(width-1))
+	putUint24(header[7:], uint32(height-1))
+	writeWebPChunk(&body, "VP8X", header)
+	for range frames {
+		writeWebPChunk(&body, "ANMF", make([]byte, anmfHeaderSize))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()+4))
+	out.WriteString("WEBP")
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func TestDecodeAnimatedWebPLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"huge canvas", animatedWebP(1<<24, 1<<24, 1), "too big"},
+		{"wide canvas", animatedWebP(16384, 1025, 1), "too big"},
+		{"too many frames", animatedWebP(16, 16, maxSourceFrames+1), "frames"},
+		// a big canvas makes every tiny frame a full snapshot
+		{"too many pixels", animatedWebP(2048, 2048, 20), "too much"},
+		{"no extended header", []byte("RIFF\x04\x00\x00\x00WEBP"), "extended header"},
+	}
+
+	for _, tt := range tests {
+		emitted := 0
+		err := decodeAnimatedWebP(context.Background(), tt.data, time.Hour, func(animationFrame) error {
+			emitted++
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want it to mention %q", tt.name, err, tt.wantErr)
+		}
+		if emitted > 0 {
+			t.Errorf("%s: %d frames were emitted", tt.name, emitted)
+		}
+	}
+}
+
+func TestDecodeAnimatedWebPCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := decodeAnimatedWebP(ctx, animatedWebP(16, 16, 3), time.Hour, func(animationFrame) error {
+		t.Error("frame emitted after cancel")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestAnmfDelay(t *testing.T) {
+	tests := []struct {
+		delay uint32
+		want  time.Duration
+	}{
+		{0, defaultWebPFrameDelay},
+		{5, defaultWebPFrameDelay},
+		{10, 10 * time.Millisecond},
+		{40, 40 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		payload := make([]byte, anmfHeaderSize)
+		putUint24(payload[12:], tt.delay)
+		if got := anmfDelay(payload); got != tt.want {
+			t.Errorf("delay %d: got %v, want %v", tt.delay, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package resize
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchQuality(t *testing.T) {
+	tests := []struct {
+		name string
+		// the highest quality that still fits, 0 for none
+		fits int
+		want int
+	}{
+		{"everything fits", maxWebPQuality, maxWebPQuality},
+		{"in the middle", 62, 62},
+		{"only the lowest", minWebPQuality, minWebPQuality},
+		{"nothing fits", 0, 0},
+	}
+
+	for _, tt := range tests {
+		var tried []int
+		// fitting outputs are smaller for lower qualities, so the size
+		// tells which quality was picked
+		output, err := searchQuality(func(quality int) ([]byte, error) {
+			tried = append(tried, quality)
+			if quality > tt.fits {
+				return make([]byte, maxStaticSize+1), nil
+			}
+			return make([]byte, maxStaticSize-maxWebPQuality+quality), nil
+		})
+
+		for _, quality := range tried {
+			if quality < minWebPQuality || quality > maxWebPQuality {
+				t.Errorf("%s: tried quality %d out of bounds", tt.name, quality)
+			}
+		}
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("%s: searchQuality succeeded, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := len(output) - maxStaticSize + maxWebPQuality; got != tt.want {
+			t.Errorf("%s: searchQuality picked %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchQualityError(t *testing.T) {
+	failed := errors.New("ffmpeg failed")
+	_, err := searchQuality(func(quality int) ([]byte, error) {
+		return nil, failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("searchQuality error = %v, want %v", err, failed)
+	}
+}
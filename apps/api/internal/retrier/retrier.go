@@ -2,11 +2,13 @@ package retrier
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -18,6 +20,25 @@ type RetryParams struct {
 	Request *http.Request
 	Client  *http.Client
 	Retries int
+	// Name is logged and put in errors instead of the url,
+	// set it when the url carries a secret
+	Name string
+}
+
+func (p *RetryParams) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Request.URL.String()
+}
+
+// hideURL puts the name in place of the url net/http adds to its errors
+func hideURL(err error, name string) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &url.Error{Op: urlErr.Op, URL: name, Err: urlErr.Err}
+	}
+	return err
 }
 
 // if err is nil, response is returned
@@ -30,20 +51,20 @@ func Download(params *RetryParams) ([]byte, error) {
 	request := params.Request
 	retries := params.Retries
 	ctx := request.Context()
-	url := request.URL
+	name := params.name()
 
 	for attempt := 1; attempt <= retries; attempt++ {
 		if err := ctx.Err(); err != nil {
 			return nil, fmt.Errorf("context cancelled: %w", err)
 		}
 
-		data, err := attemptDownload(request, client)
+		data, err := attemptDownload(request, client, name)
 		if err == nil {
 			return data, nil
 		}
 
 		log.Printf(
-			"downloading %s failed (%d/%d): %v", url, attempt, retries, err,
+			"downloading %s failed (%d/%d): %v", name, attempt, retries, err,
 		)
 
 		// don't sleep after the last attempt
@@ -54,22 +75,26 @@ func Download(params *RetryParams) ([]byte, error) {
 		}
 	}
 
-	err := fmt.Errorf("failed to download %s after %d attempts", url, retries)
+	err := fmt.Errorf("failed to download %s after %d attempts", name, retries)
 	return nil, err
 }
 
 func attemptDownload(
 	request *http.Request,
 	client *http.Client,
+	name string,
 ) ([]byte, error) {
+	if err := rewindBody(request); err != nil {
+		return nil, err
+	}
 	resp, err := client.Do(request)
 	if err != nil {
-		return nil, err
+		return nil, hideURL(err, name)
 	}
 	defer resp.Body.Close()
 
 	if statusCode := resp.StatusCode; statusCode != http.StatusOK {
-		err := fmt.Errorf("download failed: %s returned %d", request.URL, statusCode)
+		err := fmt.Errorf("download failed: %s returned %d", name, statusCode)
 		return nil, err
 	}
 
@@ -88,7 +113,7 @@ func RequestWithCallback(
 	client := params.Client
 	request := params.Request
 	retries := params.Retries
-	url := request.URL
+	name := params.name()
 
 	for attempt := 1; attempt <= retries; attempt++ {
 		if err := ctx.Err(); err != nil {
@@ -98,7 +123,8 @@ func RequestWithCallback(
 		resp, err := attemptRequest(request, client)
 		if err != nil {
 			log.Printf(
-				"request of %s failed (%d/%d): %v", url, attempt, retries, err,
+				"request of %s failed (%d/%d): %v",
+				name, attempt, retries, hideURL(err, name),
 			)
 			if attempt < retries {
 				if err := sleepWithBackoff(ctx, attempt-1); err != nil {
@@ -120,7 +146,7 @@ func RequestWithCallback(
 
 		log.Printf(
 			"callback failed for %s (%d/%d): %v",
-			url,
+			name,
 			attempt,
 			retries,
 			callbackErr,
@@ -141,9 +167,25 @@ func attemptRequest(
 	req *http.Request,
 	client *http.Client,
 ) (*http.Response, error) {
+	if err := rewindBody(req); err != nil {
+		return nil, err
+	}
 	return client.Do(req)
 }
 
+// rewindBody resets the body so POST requests can be sent more than once
+func rewindBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	req.Body = body
+	return nil
+}
+
 func invokeCallback(resp *http.Response, callback RetryCallback) (bool, error) {
 	var shouldRetry bool
 	var callbackErr error
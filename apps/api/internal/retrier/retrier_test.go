@@ -0,0 +1,103 @@
+package retrier
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const secret = "123456:SECRET"
+
+// captureLog collects what the retrier logs during the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestNameHidesURL(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	// nothing listens on a closed server, so the client itself fails
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, server := range []string{failing.URL, closed.URL} {
+		logs := captureLog(t)
+		newParams := func() *RetryParams {
+			req, err := http.NewRequest(http.MethodGet, server+"/bot"+secret+"/file", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return &RetryParams{
+				Request: req,
+				Client:  http.DefaultClient,
+				Retries: 1,
+				Name:    "bot<token>/file",
+			}
+		}
+
+		_, downloadErr := Download(newParams())
+		_, requestErr := RequestWithCallback(
+			context.Background(), newParams(),
+			func(resp *http.Response) (bool, error) {
+				return true, http.ErrNotSupported
+			},
+		)
+
+		for _, text := range []string{logs.String(), downloadErr.Error(), requestErr.Error()} {
+			if strings.Contains(text, secret) {
+				t.Errorf("%s: secret leaked in %q", server, text)
+			}
+		}
+		if !strings.Contains(logs.String(), "bot<token>/file") {
+			t.Errorf("%s: name is not logged: %q", server, logs.String())
+		}
+	}
+}
+
+// the 7tv api is queried with POST, a retry has to send the body again
+func TestRetrySendsBodyAgain(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	captureLog(t)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("query"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := &RetryParams{Request: req, Client: http.DefaultClient, Retries: 2}
+	resp, err := RequestWithCallback(
+		context.Background(), params,
+		func(resp *http.Response) (bool, error) {
+			if resp.StatusCode != http.StatusOK {
+				return true, http.ErrNotSupported
+			}
+			return false, nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 2 || bodies[0] != "query" || bodies[1] != "query" {
+		t.Errorf("server got bodies %q, want the query twice", bodies)
+	}
+}
@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/config"
+	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
+)
+
+type GetFileResponse struct {
+	Ok     bool `json:"ok"`
+	Result struct {
+		FileID       string `json:"file_id"`
+		FileUniqueID string `json:"file_unique_id"`
+		FilePath     string `json:"file_path"`
+		FileSize     int    `json:"file_size"`
+	} `json:"result"`
+}
+
+// FileURL resolves a file_id to a download link with getFile,
+// the link is valid for at least an hour
+func FileURL(
+	ctx context.Context,
+	fileID string,
+	retries int,
+) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	token := config.Load().TelegramToken()
+	reqURL := requestURL(
+		fmt.Sprintf("getFile?file_id=%s", url.QueryEscape(fileID)),
+	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed creating request: %w", err)
+	}
+	params := &retrier.RetryParams{
+		Request: req,
+		Client:  httpClient,
+		Retries: retries,
+		Name:    RedactToken(reqURL),
+	}
+	resp, err := retrier.RequestWithCallback(ctx, params, downloadLinkCallback)
+	if err != nil {
+		return "", fmt.Errorf("failed getting a download link: %w", err)
+	}
+	defer resp.Body.Close()
+
+	fileResp, err := parseGetFileResponse(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if !fileResp.Ok {
+		return "", fmt.Errorf("telegram API returned not ok")
+	}
+	if fileResp.Result.FilePath == "" {
+		return "", fmt.Errorf("file_path is empty")
+	}
+
+	fileURL := fmt.Sprintf(
+		"https://api.telegram.org/file/bot%s/%s",
+		token,
+		fileResp.Result.FilePath,
+	)
+	return fileURL, nil
+}
+
+func downloadLinkCallback(resp *http.Response) (bool, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return false, nil
+	case http.StatusBadRequest:
+		// wrong file_id, asking again won't help
+		return false, fmt.Errorf("telegram rejected the file_id")
+	default:
+		return true, fmt.Errorf(
+			"tg request for download link status code %d", resp.StatusCode,
+		)
+	}
+}
+
+func parseGetFileResponse(r io.Reader) (*GetFileResponse, error) {
+	var fileResp GetFileResponse
+	if err := json.NewDecoder(r).Decode(&fileResp); err != nil {
+		return nil, fmt.Errorf("failed to decode getFile response: %w", err)
+	}
+	return &fileResp, nil
+}
@@ -19,6 +19,27 @@ import (
 	"github.com/Traunin/stickerpack-editor/apps/api/internal/retrier"
 )
 
+// sticker set types, custom emoji sets are used inline in messages
+const (
+	StickerTypeRegular     = "regular"
+	StickerTypeCustomEmoji = "custom_emoji"
+)
+
+// telegram won't take more stickers in a set of each type
+var maxStickers = map[string]int{
+	StickerTypeRegular:     120,
+	StickerTypeCustomEmoji: 200,
+}
+
+// MaxStickers is the size limit of a set of the sticker type,
+// unknown types get the regular limit
+func MaxStickers(stickerType string) int {
+	if limit, ok := maxStickers[stickerType]; ok {
+		return limit
+	}
+	return maxStickers[StickerTypeRegular]
+}
+
 var (
 	httpClient        = &http.Client{Timeout: 15 * time.Second}
 	fetchRetires      = 3
@@ -45,6 +66,9 @@ type StickerPack struct {
 	stickers    []InputSticker
 	isPublic    bool
 	thumbnailID string
+	stickerType string
+	// custom emoji recolored to the text color, like the builtin ones
+	needsRepainting bool
 
 	nameSet      bool
 	validNameSet bool
@@ -83,6 +107,10 @@ func (sp *StickerPack) ThumbnailID() string {
 	return sp.thumbnailID
 }
 
+func (sp *StickerPack) StickerType() string {
+	return sp.stickerType
+}
+
 type Option func(*StickerPack)
 
 func WithValidName(validName string) Option {
@@ -118,17 +146,39 @@ func WithPublic(public bool) Option {
 	}
 }
 
+func WithStickerType(stickerType string) Option {
+	return func(sp *StickerPack) {
+		sp.stickerType = stickerType
+	}
+}
+
+func WithNeedsRepainting(needsRepainting bool) Option {
+	return func(sp *StickerPack) {
+		sp.needsRepainting = needsRepainting
+	}
+}
+
 func ValidPackName(name string) string {
 	botName := config.Load().BotName()
 	return fmt.Sprintf("%s_by_%s", name, botName)
 }
 
 func NewStickerPack(userID int64, opts ...Option) (*StickerPack, error) {
-	sp := &StickerPack{userID: userID}
+	sp := &StickerPack{userID: userID, stickerType: StickerTypeRegular}
 	for _, opt := range opts {
 		opt(sp)
 	}
 
+	switch sp.stickerType {
+	case StickerTypeRegular:
+		if sp.needsRepainting {
+			return nil, fmt.Errorf("only custom emoji can be repainted")
+		}
+	case StickerTypeCustomEmoji:
+	default:
+		return nil, fmt.Errorf("invalid sticker type: %q", sp.stickerType)
+	}
+
 	if sp.nameSet && sp.validNameSet {
 		return nil, fmt.Errorf("cannot use both WithName and WithValidName")
 	}
@@ -157,6 +207,14 @@ func (pack *StickerPack) Create() (string, error) {
 	if err := writer.WriteField("title", pack.title); err != nil {
 		return "", fmt.Errorf("failed to write title: %w", err)
 	}
+	if err := writer.WriteField("sticker_type", pack.stickerType); err != nil {
+		return "", fmt.Errorf("failed to write sticker_type: %w", err)
+	}
+	if pack.needsRepainting {
+		if err := writer.WriteField("needs_repainting", "true"); err != nil {
+			return "", fmt.Errorf("failed to write needs_repainting: %w", err)
+		}
+	}
 
 	inputStickers := make([]attachSticker, len(pack.stickers))
 	for i, sticker := range pack.stickers {
@@ -175,10 +233,7 @@ func (pack *StickerPack) Create() (string, error) {
 	writer.WriteField("stickers", string(jsonStickers))
 
 	for i, sticker := range pack.stickers {
-		extension := ".png"
-		if sticker.Format == "video" {
-			extension = ".webm"
-		}
+		extension := stickerExtension(sticker)
 		part, err := writer.CreateFormFile(fmt.Sprintf("sticker%d", i), fmt.Sprintf("sticker%d%s", i, extension))
 		if err != nil {
 			return "", fmt.Errorf("failed writing to request: %w", err)
@@ -309,10 +364,7 @@ func (pack *StickerPack) AddSticker(sticker InputSticker) error {
 	}
 	writer.WriteField("sticker", string(jsonSticker))
 
-	extension := ".png"
-	if sticker.Format == "video" {
-		extension = ".webm"
-	}
+	extension := stickerExtension(sticker)
 	part, err := writer.CreateFormFile(
 		"sticker0",
 		fmt.Sprintf("sticker0%s", extension),
@@ -445,6 +497,7 @@ func FetchPack(ctx context.Context, name string) (*StickerSet, error) {
 		Request: req,
 		Client:  httpClient,
 		Retries: fetchRetires,
+		Name:    RedactToken(url),
 	}
 	resp, err := retrier.RequestWithCallback(ctx, params, fetchCallback)
 	if err != nil {
@@ -498,11 +551,39 @@ func parseFetchResponse(r io.Reader) (*StickerSet, error) {
 	return &set.Result, nil
 }
 
+func stickerExtension(sticker InputSticker) string {
+	switch sticker.Format {
+	case "video":
+		return ".webm"
+	case "animated":
+		return ".tgs"
+	}
+
+	// static stickers are png unless they had to be squeezed into webp
+	data := sticker.Sticker
+	if len(data) >= 12 &&
+		bytes.Equal(data[:4], []byte("RIFF")) &&
+		bytes.Equal(data[8:12], []byte("WEBP")) {
+		return ".webp"
+	}
+	return ".png"
+}
+
 func requestURL(method string) string {
 	token := config.Load().TelegramToken()
 	return fmt.Sprintf("https://api.telegram.org/bot%s/%s", token, method)
 }
 
+// RedactToken hides the bot token in api and file urls before they
+// are logged
+func RedactToken(s string) string {
+	token := config.Load().TelegramToken()
+	if token == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, token, "<token>")
+}
+
 func isValidPackName(name string) bool {
 	// English letters and digits, underscores
 	// <= 64 characters
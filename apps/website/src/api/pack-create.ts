@@ -10,6 +10,8 @@ export interface CreatePackRequest {
   emotes: Sticker[]
   has_watermark: boolean
   is_public: boolean
+  sticker_type?: 'regular' | 'custom_emoji'
+  needs_repainting?: boolean
 }
 
 export interface CreatePackResponse {
@@ -39,10 +39,10 @@ export async function searchTenor(query: string, pos = '', limit = 10) {
   return {
     items: items.map((e): Emote => {
       const fullUrl = e.media_formats.gif?.url ?? e.media_formats.mediumgif?.url ?? ''
-      const id = fullUrl ? new URL(fullUrl).pathname.slice(1) : ''
 
+      // post ids let the api pick the best rendition and read the tags
       return {
-        id,
+        id: e.id,
         name: e.title || e.content_description,
         preview: e.media_formats.tinygif?.url ?? e.media_formats.nanogif?.url ?? '',
         full: fullUrl,
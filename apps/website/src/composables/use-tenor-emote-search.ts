@@ -36,7 +36,7 @@ async function fetchTenor(query: string, pos = '', limit = 10) {
 
   return {
     items: items.map((e): Emote => ({
-      id: e.media_formats.gif.url.replace('https://media.tenor.com/', ''),
+      id: e.id,
       name: e.title || e.id,
       preview: e.media_formats.tinygif?.url ?? e.media_formats.nanogif?.url ?? '',
       full: e.media_formats.gif?.url ?? e.media_formats.mediumgif?.url ?? '',